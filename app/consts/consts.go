@@ -3,9 +3,10 @@ package consts
 type PlatformType string
 
 const (
-	PlatformAll        PlatformType = "all"
-	PlatformTrustpilot PlatformType = "trustpilot"
-	PlatformAmazon     PlatformType = "amazon"
+	PlatformAll         PlatformType = "all"
+	PlatformTrustpilot  PlatformType = "trustpilot"
+	PlatformAmazon      PlatformType = "amazon"
+	PlatformTripadvisor PlatformType = "tripadvisor"
 )
 
 type TimePeriodType string
@@ -25,3 +26,89 @@ var TimePeriods = []TimePeriodType{
 	TimePeriodLastMonth,
 	TimePeriodAllTime,
 }
+
+type ScrapeModeType string
+
+const (
+	ScrapeModeIncremental ScrapeModeType = "incremental"
+	ScrapeModeBackfill    ScrapeModeType = "backfill"
+)
+
+type BackfillStatusType string
+
+const (
+	BackfillStatusPending   BackfillStatusType = "pending"
+	BackfillStatusRunning   BackfillStatusType = "running"
+	BackfillStatusCompleted BackfillStatusType = "completed"
+	BackfillStatusFailed    BackfillStatusType = "failed"
+)
+
+type ReplyDraftStatusType string
+
+const (
+	ReplyDraftStatusDraft    ReplyDraftStatusType = "draft"
+	ReplyDraftStatusApproved ReplyDraftStatusType = "approved"
+	ReplyDraftStatusPosted   ReplyDraftStatusType = "posted"
+)
+
+type ConversationRoleType string
+
+const (
+	ConversationRoleUser      ConversationRoleType = "user"
+	ConversationRoleAssistant ConversationRoleType = "assistant"
+)
+
+type AnomalyMetricType string
+
+const (
+	AnomalyMetricReviewCount   AnomalyMetricType = "review_count"
+	AnomalyMetricAverageRating AnomalyMetricType = "average_rating"
+)
+
+type AnomalyDirectionType string
+
+const (
+	AnomalyDirectionSpike AnomalyDirectionType = "spike"
+	AnomalyDirectionDrop  AnomalyDirectionType = "drop"
+)
+
+// SuspicionReasonType is why a review looks fake or spammy
+type SuspicionReasonType string
+
+const (
+	SuspicionReasonDuplicateText   SuspicionReasonType = "duplicate_text"
+	SuspicionReasonBurst           SuspicionReasonType = "burst"
+	SuspicionReasonShortGeneric    SuspicionReasonType = "short_generic"
+	SuspicionReasonReviewerSameDay SuspicionReasonType = "reviewer_same_day"
+	SuspicionReasonLLMJudgment     SuspicionReasonType = "llm_judgment"
+)
+
+type APIKeyScopeType string
+
+const (
+	APIKeyScopeReadReviews   APIKeyScopeType = "read:reviews"
+	APIKeyScopeWriteProducts APIKeyScopeType = "write:products"
+	APIKeyScopeGenerateStats APIKeyScopeType = "generate:stats"
+)
+
+var APIKeyScopes = []APIKeyScopeType{
+	APIKeyScopeReadReviews,
+	APIKeyScopeWriteProducts,
+	APIKeyScopeGenerateStats,
+}
+
+type OrganizationRoleType string
+
+const (
+	OrganizationRoleOwner  OrganizationRoleType = "owner"
+	OrganizationRoleAdmin  OrganizationRoleType = "admin"
+	OrganizationRoleViewer OrganizationRoleType = "viewer"
+)
+
+type PlanType string
+
+const (
+	PlanFree       PlanType = "free"
+	PlanPro        PlanType = "pro"
+	PlanEnterprise PlanType = "enterprise"
+)
@@ -54,6 +54,22 @@ func (d *Database) NamedExecContextReturnObj(ctx context.Context, query string,
 	return d.Sqlx.QueryRowx(q, args...).StructScan(obj)
 }
 
+// InTransaction runs fn in a transaction, committing it when fn succeeds and
+// rolling it back otherwise
+func (d *Database) InTransaction(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := d.Sqlx.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func stringSliceContains(slice []string, target string) bool {
 	for _, element := range slice {
 		if element == target {
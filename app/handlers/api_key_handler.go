@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/middleware"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/utils"
+)
+
+type CreateAPIKeyBody struct {
+	Name      string     `json:"name" validate:"min=1,max=100"`
+	Scopes    []string   `json:"scopes" validate:"min=1,dive,oneof=read:reviews write:products generate:stats"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is returned once when a key is created. The plaintext key
+// cannot be retrieved afterwards.
+type CreatedAPIKey struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+func HandlerCreateAPIKey(c *gin.Context) {
+	ctx := context.Background()
+
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var body CreateAPIKeyBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErrors.Error()})
+		return
+	}
+
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	key, prefix, hash, err := utils.GenerateAPIKey()
+	if err != nil {
+		log.Error("Error while generating api key", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create API key"})
+		return
+	}
+
+	apiKey := &models.APIKey{
+		UserID:    contextUser.ID,
+		Name:      body.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    body.Scopes,
+		ExpiresAt: body.ExpiresAt,
+	}
+
+	if err := models.CreateAPIKey(ctx, apiKey); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreatedAPIKey{APIKey: apiKey, Key: key})
+}
+
+func HandlerGetAPIKeys(c *gin.Context) {
+	ctx := context.Background()
+
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	apiKeys, err := models.GetAPIKeysByUserID(ctx, contextUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+func HandlerRevokeAPIKey(c *gin.Context) {
+	ctx := context.Background()
+
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	apiKeyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	err = models.RevokeAPIKey(ctx, apiKeyID, contextUser.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke API key"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/middleware"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/policy"
+	"github.com/review-aggregator/review-api/app/services"
+)
+
+// CompareProductsBody lists the team's own product first, then its competitors
+type CompareProductsBody struct {
+	ProductIDs []uuid.UUID `json:"product_ids" validate:"min=2,max=5"`
+	Platform   string      `json:"platform"`
+	TimePeriod string      `json:"time_period"`
+}
+
+func HandlerCompareProducts(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var body CompareProductsBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	platform := consts.PlatformType(body.Platform)
+	if platform == "" {
+		platform = consts.PlatformAll
+	}
+	timePeriod := consts.TimePeriodType(body.TimePeriod)
+	if timePeriod == "" {
+		timePeriod = consts.TimePeriodAllTime
+	}
+	if !slices.Contains(consts.TimePeriods, timePeriod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time period"})
+		return
+	}
+
+	// Every product is authorized like ProductPolicy does for a single one
+	products := make([]*models.Product, 0, len(body.ProductIDs))
+	for _, productID := range body.ProductIDs {
+		if slices.ContainsFunc(products, func(product *models.Product) bool { return product.ID == productID }) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Products can only be compared once"})
+			return
+		}
+
+		product, err := models.GetProductByIDAndUserID(context.Background(), productID, contextUser.ID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found", "product_id": productID})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch product"})
+			return
+		}
+
+		if !policy.Can(product.Role, policy.ActionViewStats) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your role does not allow " + string(policy.ActionViewStats), "product_id": productID})
+			return
+		}
+
+		products = append(products, product)
+	}
+
+	// The comparison is paid for by the team's own product
+	if err := services.UseStatsGenerationQuota(context.Background(), &contextUser, products[0].ID); err != nil {
+		if !respondQuotaError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check stats generation quota"})
+		}
+		return
+	}
+
+	ctx := services.WithLLMAttribution(context.Background(), products[0].ID, contextUser.ID)
+	comparison, err := services.CompareProducts(ctx, products, platform, timePeriod)
+	if err != nil {
+		log.Error("Error while comparing products", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not compare products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/middleware"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/services"
+)
+
+// AskQuestionBody is a question about a product's reviews. The optional
+// filters narrow down the reviews the question is answered from.
+type AskQuestionBody struct {
+	Question string `json:"question" validate:"required,max=1000"`
+	// ConversationID continues an earlier conversation, a new one is started
+	// when it is empty
+	ConversationID *uuid.UUID `json:"conversation_id"`
+	Platform       string     `json:"platform"`
+	Since          string     `json:"since" validate:"omitempty,datetime=2006-01-02"`
+	Until          string     `json:"until" validate:"omitempty,datetime=2006-01-02"`
+	MinRating      *float64   `json:"min_rating" validate:"omitempty,min=1,max=5"`
+	MaxRating      *float64   `json:"max_rating" validate:"omitempty,min=1,max=5"`
+}
+
+func HandlerAskProductQuestion(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var body AskQuestionBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	search := models.ReviewSearch{
+		ProductID: product.ID,
+		UserID:    contextUser.ID,
+		Query:     body.Question,
+		Platform:  consts.PlatformType(body.Platform),
+		DateFrom:  "1970-01-01",
+		DateTo:    time.Now().Format("2006-01-02"),
+		MinRating: 1,
+		MaxRating: 5,
+	}
+	if body.Since != "" {
+		search.DateFrom = body.Since
+	}
+	if body.Until != "" {
+		search.DateTo = body.Until
+	}
+	if body.MinRating != nil {
+		search.MinRating = *body.MinRating
+	}
+	if body.MaxRating != nil {
+		search.MaxRating = *body.MaxRating
+	}
+
+	var conversation *models.Conversation
+	history := []*models.ConversationMessage{}
+	if body.ConversationID != nil {
+		conversation, err = models.GetConversationByIDAndUserID(context.Background(), *body.ConversationID, product.ID, contextUser.ID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get conversation"})
+			return
+		}
+
+		history, err = models.GetConversationMessages(context.Background(), conversation.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get conversation messages"})
+			return
+		}
+	}
+
+	reviews, err := services.RetrieveReviews(context.Background(), search)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get reviews"})
+		return
+	}
+	if len(reviews) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No reviews match the filters"})
+		return
+	}
+
+	// Every answer is an LLM call, so it shares the stats generation quota
+	if err := services.UseStatsGenerationQuota(context.Background(), &contextUser, product.ID); err != nil {
+		if !respondQuotaError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check stats generation quota"})
+		}
+		return
+	}
+
+	ctx := services.WithLLMAttribution(context.Background(), product.ID, contextUser.ID)
+	answer, err := services.AnswerQuestion(ctx, product, body.Question, history, reviews)
+	if err != nil {
+		log.Error("Error while answering question", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not answer question"})
+		return
+	}
+
+	if conversation == nil {
+		conversation = &models.Conversation{
+			ProductID: product.ID,
+			UserID:    contextUser.ID,
+			Title:     services.ConversationTitle(body.Question),
+		}
+		if err := models.CreateConversation(context.Background(), conversation); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create conversation"})
+			return
+		}
+	}
+
+	question := &models.ConversationMessage{
+		ConversationID: conversation.ID,
+		Role:           consts.ConversationRoleUser,
+		Content:        body.Question,
+	}
+	if err := models.CreateConversationMessage(context.Background(), question); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save question"})
+		return
+	}
+
+	reply := &models.ConversationMessage{
+		ConversationID: conversation.ID,
+		Role:           consts.ConversationRoleAssistant,
+		Content:        answer.Answer,
+		ReviewIDs:      pq.StringArray(answer.ReviewIDs),
+		PromptVersion:  &answer.PromptVersion,
+	}
+	if err := models.CreateConversationMessage(context.Background(), reply); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save answer"})
+		return
+	}
+
+	citations := make([]*models.Review, 0, len(answer.ReviewIDs))
+	for _, reviewID := range answer.ReviewIDs {
+		for _, review := range reviews {
+			if review.ID.String() == reviewID {
+				citations = append(citations, review)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversation_id": conversation.ID, "message": reply, "citations": citations})
+}
+
+func HandlerGetProductConversations(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	conversations, err := models.GetConversationsByProductIDAndUserID(context.Background(), product.ID, contextUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get conversations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, conversations)
+}
+
+func HandlerGetProductConversation(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	conversationID, err := uuid.Parse(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	conversation, err := models.GetConversationByIDAndUserID(context.Background(), conversationID, product.ID, contextUser.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get conversation"})
+		return
+	}
+
+	messages, err := models.GetConversationMessages(context.Background(), conversation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get conversation messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversation": conversation, "messages": messages})
+}
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/middleware"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+type CreateOrganizationBody struct {
+	Name string `json:"name" validate:"min=1,max=100"`
+}
+
+type AddOrganizationMemberBody struct {
+	Email string                      `json:"email" validate:"required,email"`
+	Role  consts.OrganizationRoleType `json:"role" validate:"oneof=owner admin viewer"`
+}
+
+type UpdateOrganizationMemberBody struct {
+	Role consts.OrganizationRoleType `json:"role" validate:"oneof=owner admin viewer"`
+}
+
+// defaultOrganization returns the user's oldest owned organization, creating
+// a personal one for users that have none yet
+func defaultOrganization(ctx context.Context, user *models.User) (*models.Organization, error) {
+	organization, err := models.GetDefaultOrganizationByUserID(ctx, user.ID)
+	if err == sql.ErrNoRows {
+		return models.CreateOrganization(ctx, user.Name+"'s workspace", user.ID)
+	}
+
+	return organization, err
+}
+
+// canManageMembers reports whether role may add, change or remove members
+// with the given role. Only owners can grant or take away ownership.
+func canManageMembers(role, memberRole consts.OrganizationRoleType) bool {
+	switch role {
+	case consts.OrganizationRoleOwner:
+		return true
+	case consts.OrganizationRoleAdmin:
+		return memberRole != consts.OrganizationRoleOwner
+	default:
+		return false
+	}
+}
+
+// getContextOrganization loads the :organization_id organization for the
+// context user and writes the error response if they are not a member
+func getContextOrganization(c *gin.Context, user *models.User) (*models.Organization, bool) {
+	organizationID, err := uuid.Parse(c.Param("organization_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return nil, false
+	}
+
+	organization, err := models.GetOrganizationByIDAndUserID(context.Background(), organizationID, user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organization"})
+		return nil, false
+	}
+
+	return organization, true
+}
+
+// isLastOwner reports whether member is the organization's only owner, who
+// can neither leave nor be demoted
+func isLastOwner(ctx context.Context, member *models.OrganizationMember) (bool, error) {
+	if member.Role != consts.OrganizationRoleOwner {
+		return false, nil
+	}
+
+	owners, err := models.CountOrganizationOwners(ctx, member.OrganizationID)
+	if err != nil {
+		return false, err
+	}
+
+	return owners <= 1, nil
+}
+
+func HandlerCreateOrganization(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var body CreateOrganizationBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErrors.Error()})
+		return
+	}
+
+	organization, err := models.CreateOrganization(context.Background(), body.Name, contextUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create organization"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+func HandlerGetOrganizations(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	organizations, err := models.GetOrganizationsByUserID(context.Background(), contextUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+func HandlerGetOrganizationMembers(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	organization, ok := getContextOrganization(c, &contextUser)
+	if !ok {
+		return
+	}
+
+	members, err := models.GetOrganizationMembers(context.Background(), organization.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organization members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func HandlerAddOrganizationMember(c *gin.Context) {
+	ctx := context.Background()
+
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	organization, ok := getContextOrganization(c, &contextUser)
+	if !ok {
+		return
+	}
+
+	var body AddOrganizationMemberBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErrors.Error()})
+		return
+	}
+
+	if !canManageMembers(organization.Role, body.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to add members with this role"})
+		return
+	}
+
+	user, err := models.GetUserByEmail(ctx, body.Email)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch user"})
+		return
+	}
+
+	_, err = models.GetOrganizationMember(ctx, organization.ID, user.ID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organization member"})
+		return
+	}
+
+	if err := models.AddOrganizationMember(ctx, organization.ID, user.ID, body.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add organization member"})
+		return
+	}
+
+	member, err := models.GetOrganizationMember(ctx, organization.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organization member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// getContextMember loads the :user_id member of organization and checks the
+// context user may manage them. With allowSelf, members may act on themselves.
+func getContextMember(c *gin.Context, organization *models.Organization, user *models.User, allowSelf bool) (*models.OrganizationMember, bool) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	member, err := models.GetOrganizationMember(context.Background(), organization.ID, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization member not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organization member"})
+		return nil, false
+	}
+
+	if !(allowSelf && member.UserID == user.ID) && !canManageMembers(organization.Role, member.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to manage this member"})
+		return nil, false
+	}
+
+	return member, true
+}
+
+func HandlerUpdateOrganizationMember(c *gin.Context) {
+	ctx := context.Background()
+
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	organization, ok := getContextOrganization(c, &contextUser)
+	if !ok {
+		return
+	}
+
+	var body UpdateOrganizationMemberBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErrors.Error()})
+		return
+	}
+
+	member, ok := getContextMember(c, organization, &contextUser, false)
+	if !ok {
+		return
+	}
+
+	if !canManageMembers(organization.Role, body.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to grant this role"})
+		return
+	}
+
+	if body.Role != consts.OrganizationRoleOwner {
+		lastOwner, err := isLastOwner(ctx, member)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update organization member"})
+			return
+		}
+		if lastOwner {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization must keep at least one owner"})
+			return
+		}
+	}
+
+	if err := models.UpdateOrganizationMemberRole(ctx, organization.ID, member.UserID, body.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update organization member"})
+		return
+	}
+
+	member.Role = body.Role
+	c.JSON(http.StatusOK, member)
+}
+
+func HandlerRemoveOrganizationMember(c *gin.Context) {
+	ctx := context.Background()
+
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	organization, ok := getContextOrganization(c, &contextUser)
+	if !ok {
+		return
+	}
+
+	member, ok := getContextMember(c, organization, &contextUser, true)
+	if !ok {
+		return
+	}
+
+	lastOwner, err := isLastOwner(ctx, member)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not remove organization member"})
+		return
+	}
+	if lastOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization must keep at least one owner"})
+		return
+	}
+
+	if err := models.RemoveOrganizationMember(ctx, organization.ID, member.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not remove organization member"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -5,9 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
 	"github.com/review-aggregator/review-api/app/middleware"
 	"github.com/review-aggregator/review-api/app/models"
 	"github.com/review-aggregator/review-api/app/services"
@@ -27,7 +30,9 @@ func HandlerCreatePlatform(c *gin.Context) {
 		return
 	}
 
-	services.ScrapeTrustpilot(context.Background(), &platform, "")
+	if _, err := services.StartBackfill(context.Background(), &platform, nil, nil, nil); err != nil {
+		log.Error("Error while starting backfill", err)
+	}
 
 	c.JSON(http.StatusCreated, platform)
 }
@@ -70,34 +75,121 @@ func HandlerRunPlatformScraper(c *gin.Context) {
 		return
 	}
 
-	latestReviewDate, err := models.GetLatestReviewDateByPlatformID(context.Background(), platformID)
-	if err != nil && err != sql.ErrNoRows {
-		fmt.Println("Error while fetching latest review date", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch latest review date"})
+	mode := consts.ScrapeModeType(c.DefaultQuery("mode", string(consts.ScrapeModeIncremental)))
+	if mode != consts.ScrapeModeIncremental && mode != consts.ScrapeModeBackfill {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scrape mode"})
 		return
 	}
 
-	switch platform.Name {
-	case "trustpilot":
-		fmt.Println("Scraping trustpilot")
-		err = services.ScrapeTrustpilot(context.Background(), platform, latestReviewDate)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not scrape platform"})
-			return
-		}
-	case "tripadvisor":
-		fmt.Println("Scraping tripadvisor")
-		reviews, err := services.ScrapeTripadvisor(context.Background(), platform, latestReviewDate, 100)
-		if err != nil {
+	if !checkPlatformReviewQuota(c, platform) {
+		return
+	}
+
+	fmt.Println("Scraping", platform.Name, "in mode", mode)
+	err = services.RunPlatformScraper(context.Background(), platform, mode)
+	if err != nil {
+		fmt.Println("Error while scraping platform", err)
+		if !respondQuotaError(c, err) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not scrape platform"})
-			return
-		}
-		err = models.CreateReviews(context.Background(), reviews, platform.ID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create reviews"})
-			return
 		}
+		return
 	}
 
 	c.Status(http.StatusOK)
 }
+
+// checkPlatformReviewQuota writes the error response and returns false when
+// the platform's product cannot store more reviews
+func checkPlatformReviewQuota(c *gin.Context, platform *models.Platform) bool {
+	product, err := models.GetProductByID(context.Background(), platform.ProductID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch product"})
+		return false
+	}
+
+	if err := services.CheckReviewQuota(context.Background(), product, 1); err != nil {
+		if !respondQuotaError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check review quota"})
+		}
+		return false
+	}
+
+	return true
+}
+
+type CreateBackfillJobBody struct {
+	TargetReviews    *int       `json:"target_reviews" validate:"omitempty,min=1"`
+	TargetSince      *time.Time `json:"target_since"`
+	PageDelaySeconds *int       `json:"page_delay_seconds" validate:"omitempty,min=0,max=300"`
+}
+
+func HandlerCreateBackfillJob(c *gin.Context) {
+	platformID, err := uuid.Parse(c.Param("platform_id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var body CreateBackfillJobBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	platform, err := models.GetPlatformByID(context.Background(), platformID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Platform not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch platform"})
+		return
+	}
+
+	if !checkPlatformReviewQuota(c, platform) {
+		return
+	}
+
+	activeJob, err := models.GetActiveBackfillJobByPlatformID(context.Background(), platform.ID)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch backfill jobs"})
+		return
+	}
+	if activeJob != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Backfill already running for platform", "job": activeJob})
+		return
+	}
+
+	job, err := services.StartBackfill(context.Background(), platform, body.TargetReviews, body.TargetSince, body.PageDelaySeconds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start backfill"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+func HandlerGetBackfillJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	job, err := models.GetBackfillJobByID(context.Background(), jobID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backfill job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch backfill job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
@@ -5,11 +5,15 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
 	"github.com/review-aggregator/review-api/app/consts"
 	"github.com/review-aggregator/review-api/app/middleware"
 	"github.com/review-aggregator/review-api/app/models"
@@ -21,6 +25,8 @@ type CreateProductBody struct {
 	Description string `json:"description" validate:"min=1"`
 	Platform    string `json:"platform" validate:"oneof=trustpilot amazon"`
 	ProductURL  string `json:"product_url" validate:"required,url"`
+	// OrganizationID defaults to the user's own organization
+	OrganizationID *uuid.UUID `json:"organization_id"`
 }
 
 func HandlerCreateProduct(c *gin.Context) {
@@ -60,7 +66,34 @@ func HandlerCreateProduct(c *gin.Context) {
 		}
 	}
 
-	productExists, err := models.GetProductByNameAndUserID(context.Background(), body.Name, contextUser.ID)
+	var organization *models.Organization
+	if body.OrganizationID != nil {
+		organization, err = models.GetOrganizationByIDAndUserID(context.Background(), *body.OrganizationID, contextUser.ID)
+	} else {
+		organization, err = defaultOrganization(context.Background(), &contextUser)
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organization"})
+		return
+	}
+
+	if organization.Role == consts.OrganizationRoleViewer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Viewers cannot create products"})
+		return
+	}
+
+	if err := services.CheckProductQuota(context.Background(), &contextUser); err != nil {
+		if !respondQuotaError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check product quota"})
+		}
+		return
+	}
+
+	productExists, err := models.GetProductByNameAndOrganizationID(context.Background(), body.Name, organization.ID)
 	if err != nil && err != sql.ErrNoRows {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch product"})
 		return
@@ -72,10 +105,12 @@ func HandlerCreateProduct(c *gin.Context) {
 	}
 
 	product := models.Product{
-		ID:          uuid.New(),
-		Name:        body.Name,
-		Description: body.Description,
-		UserID:      contextUser.ID,
+		ID:             uuid.New(),
+		Name:           body.Name,
+		Description:    body.Description,
+		UserID:         contextUser.ID,
+		OrganizationID: organization.ID,
+		Role:           organization.Role,
 	}
 
 	if err := models.CreateProduct(context.Background(), &product); err != nil {
@@ -99,7 +134,9 @@ func HandlerCreateProduct(c *gin.Context) {
 		return
 	}
 
-	services.ScrapeTrustpilot(context.Background(), &platform, "")
+	if _, err := services.StartBackfill(context.Background(), &platform, nil, nil, nil); err != nil {
+		log.Error("Error while starting backfill", err)
+	}
 
 	c.JSON(http.StatusCreated, product)
 }
@@ -127,14 +164,13 @@ func HandlerGetProductByID(c *gin.Context) {
 		return
 	}
 
-	productID := c.Param("product_id")
-	product, err := models.GetProductByIDAndUserID(context.Background(), uuid.MustParse(productID), contextUser.ID)
+	product, err := middleware.GetContextProduct(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch product"})
+		c.Status(http.StatusInternalServerError)
 		return
 	}
 
-	platforms, err := models.GetPlatformsByProductIDAndUserID(context.Background(), uuid.MustParse(productID), contextUser.ID)
+	platforms, err := models.GetPlatformsByProductIDAndUserID(context.Background(), product.ID, contextUser.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch platforms"})
 		return
@@ -152,8 +188,13 @@ func HandlerDeleteProduct(c *gin.Context) {
 		return
 	}
 
-	productID := c.Param("product_id")
-	err = models.DeleteProduct(context.Background(), uuid.MustParse(productID), contextUser.ID)
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	err = models.DeleteProduct(context.Background(), product.ID, contextUser.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete product"})
 		return
@@ -174,19 +215,12 @@ type UpdateProductBody struct {
 }
 
 func HandlerUpdateProduct(c *gin.Context) {
-	contextUser, err := middleware.GetContextUser(c)
+	product, err := middleware.GetContextProduct(c)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
 
-	productID := c.Param("product_id")
-	product, err := models.GetProductByIDAndUserID(context.Background(), uuid.MustParse(productID), contextUser.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch product"})
-		return
-	}
-
 	var body UpdateProductBody
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
@@ -210,6 +244,13 @@ func HandlerUpdateProduct(c *gin.Context) {
 		}
 
 		if err == sql.ErrNoRows {
+			if err := services.CheckPlatformQuota(context.Background(), product, 1); err != nil {
+				if !respondQuotaError(c, err) {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check platform quota"})
+				}
+				return
+			}
+
 			existingPlatform = &models.Platform{
 				ID:        uuid.New(),
 				Name:      consts.PlatformType(platform.Name),
@@ -221,6 +262,10 @@ func HandlerUpdateProduct(c *gin.Context) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create platform"})
 				return
 			}
+
+			if _, err := services.StartBackfill(context.Background(), existingPlatform, nil, nil, nil); err != nil {
+				log.Error("Error while starting backfill", err)
+			}
 		} else {
 			existingPlatform.URL = platform.URL
 			err = models.UpdatePlatform(context.Background(), existingPlatform.ID, platform.URL)
@@ -235,15 +280,26 @@ func HandlerUpdateProduct(c *gin.Context) {
 }
 
 func HandlerGenerateProductStats(c *gin.Context) {
-	// contextUser, err := middleware.GetContextUser(c)
-	// if err != nil {
-	// 	c.Status(http.StatusInternalServerError)
-	// 	return
-	// }
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
 
-	productID := c.Param("product_id")
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if err := services.UseStatsGenerationQuota(context.Background(), &contextUser, product.ID); err != nil {
+		if !respondQuotaError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check stats generation quota"})
+		}
+		return
+	}
 
-	err := services.GenerateProductStats(context.Background(), uuid.MustParse(productID), uuid.MustParse("027232a8-8081-4f8f-8620-f4995b580c55"))
+	err = services.GenerateProductStats(context.Background(), product.ID, contextUser.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get product stats", "details": err.Error()})
 		return
@@ -257,6 +313,118 @@ func HandlerGenerateProductStats(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+// Events sent by HandlerStreamProductSummary
+const (
+	summaryEventProgress = "progress"
+	summaryEventChunk    = "chunk"
+	summaryEventDone     = "done"
+	summaryEventError    = "error"
+)
+
+// HandlerStreamProductSummary generates the summary of a product's reviews
+// for a platform and time period and streams the model's output as
+// server-sent events. The summary is stored in the product's stats when done.
+func HandlerStreamProductSummary(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	platform := consts.PlatformType(c.DefaultQuery("platform", string(consts.PlatformAll)))
+	timePeriod := consts.TimePeriodType(c.DefaultQuery("time_period", string(consts.TimePeriodAllTime)))
+	if !slices.Contains(consts.TimePeriods, timePeriod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time period"})
+		return
+	}
+
+	// The request's context stops generating once the client disconnects
+	ctx := services.WithLLMAttribution(c.Request.Context(), product.ID, contextUser.ID)
+
+	reviews, err := getPlatformReviews(ctx, product.ID, contextUser.ID, platform, timePeriod)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Platform not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get reviews"})
+		return
+	}
+	// Checked before charging the quota, the summary would be empty otherwise
+	reviews = services.ExcludeSuspiciousReviews(reviews)
+	if len(reviews) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No reviews to summarize"})
+		return
+	}
+
+	if err := services.UseStatsGenerationQuota(ctx, &contextUser, product.ID); err != nil {
+		if !respondQuotaError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check stats generation quota"})
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Keeps proxies such as nginx from buffering the events
+	c.Header("X-Accel-Buffering", "no")
+
+	sendEvent := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	sendEvent(summaryEventProgress, gin.H{"stage": "generating", "reviews": len(reviews)})
+
+	stats, err := services.StreamProductStats(ctx, reviews, product.Description, func(chunk string) {
+		sendEvent(summaryEventChunk, gin.H{"content": chunk})
+	})
+	if err != nil {
+		log.Error("Error while streaming product summary", err)
+		sendEvent(summaryEventError, gin.H{"error": "Could not generate summary"})
+		return
+	}
+
+	stats.ProductID = product.ID
+	stats.Platform = platform
+	stats.TimePeriod = timePeriod
+	if err := models.UpsertProductStatsSummary(ctx, stats); err != nil {
+		sendEvent(summaryEventError, gin.H{"error": "Could not save summary"})
+		return
+	}
+
+	sendEvent(summaryEventDone, stats)
+}
+
+// getPlatformReviews returns the product's reviews in the time period from
+// the platform, or from all platforms for consts.PlatformAll. It returns
+// sql.ErrNoRows if the product has no such platform.
+func getPlatformReviews(ctx context.Context, productID, userID uuid.UUID, platform consts.PlatformType, timePeriod consts.TimePeriodType) ([]*models.Review, error) {
+	if platform == consts.PlatformAll {
+		return models.GetReviewsByProductIDAndUserIDAndTimePeriod(ctx, productID, userID, timePeriod)
+	}
+
+	platforms, err := models.GetPlatformsByProductIDAndUserID(ctx, productID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, productPlatform := range platforms {
+		if productPlatform.Name == platform {
+			return models.GetReviewsByPlatformIDAndUserIDAndTimePeriod(ctx, productPlatform.ID, userID, timePeriod)
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
 // func HandlerGetProductStats(c *gin.Context) {
 // 	productID := c.Param("product_id")
 // 	stats, err := models.GetProductStats(context.Background(), uuid.MustParse(productID))
@@ -268,6 +436,83 @@ func HandlerGenerateProductStats(c *gin.Context) {
 // 	c.JSON(http.StatusOK, stats)
 // }
 
+// trendDefaultDays is how far back trends go per interval without ?since
+var trendDefaultDays = map[string]int{"day": 90, "week": 364, "month": 730}
+
+// Moving averages cover defaultTrendWindow buckets unless ?window says otherwise
+const (
+	defaultTrendWindow = 7
+	maxTrendWindow     = 90
+)
+
+// HandlerGetProductTrends returns the review count, average rating and rating
+// distribution of the product per ?interval, for each platform and all of them
+func HandlerGetProductTrends(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	query := models.ReviewTrendQuery{
+		ProductID: product.ID,
+		UserID:    contextUser.ID,
+		Platform:  consts.PlatformType(c.DefaultQuery("platform", string(consts.PlatformAll))),
+		Interval:  c.DefaultQuery("interval", "day"),
+		Window:    defaultTrendWindow,
+		DateTo:    time.Now().UTC().Format(time.DateOnly),
+	}
+
+	defaultDays, ok := trendDefaultDays[query.Interval]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be one of day, week or month"})
+		return
+	}
+
+	if windowParam := c.Query("window"); windowParam != "" {
+		window, err := strconv.Atoi(windowParam)
+		if err != nil || window < 1 || window > maxTrendWindow {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window must be a number between 1 and %d", maxTrendWindow)})
+			return
+		}
+		query.Window = window
+	}
+
+	for param, date := range map[string]*string{"since": &query.DateFrom, "until": &query.DateTo} {
+		if value := c.Query(param); value != "" {
+			if _, err := time.Parse(time.DateOnly, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be a date formatted YYYY-MM-DD"})
+				return
+			}
+			*date = value
+		}
+	}
+
+	// Without since the range covers the default days up to until
+	if query.DateFrom == "" {
+		until, _ := time.Parse(time.DateOnly, query.DateTo)
+		query.DateFrom = until.AddDate(0, 0, -defaultDays).Format(time.DateOnly)
+	}
+	if query.DateFrom > query.DateTo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must not be after until"})
+		return
+	}
+
+	trends, err := models.GetReviewTrends(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get review trends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"interval": query.Interval, "window": query.Window, "since": query.DateFrom, "until": query.DateTo, "trends": trends})
+}
+
 func HandlerInsertProductStats(c *gin.Context) {
 	var body models.ProductStats
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -286,11 +531,17 @@ func HandlerInsertProductStats(c *gin.Context) {
 }
 
 func HandlerGetProductStats(c *gin.Context) {
-	productID := c.Param("product_id")
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	productID := product.ID
 	platform := c.Query("platform")
 	timePeriod := c.Query("time_period")
 
-	stats, err := models.GetProductStats(context.Background(), uuid.MustParse(productID), consts.PlatformType(platform), consts.TimePeriodType(timePeriod))
+	stats, err := models.GetProductStats(context.Background(), productID, consts.PlatformType(platform), consts.TimePeriodType(timePeriod))
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No stats found for product"})
 		return
@@ -300,11 +551,188 @@ func HandlerGetProductStats(c *gin.Context) {
 		return
 	}
 
-	reviewRatings, err := models.GetReviewRatings(context.Background(), uuid.MustParse(productID), consts.PlatformType(platform), consts.TimePeriodType(timePeriod))
+	// Suspicious reviews are left out of the ratings with ?exclude_suspicious=true
+	excludeSuspicious := config.Config.ExcludeSuspiciousReviews
+	if value := c.Query("exclude_suspicious"); value != "" {
+		excludeSuspicious, err = strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exclude_suspicious must be true or false"})
+			return
+		}
+	}
+
+	reviewRatings, err := models.GetReviewRatings(context.Background(), productID, consts.PlatformType(platform), consts.TimePeriodType(timePeriod), services.SuspicionThreshold(excludeSuspicious))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get review ratings", "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"stats": stats, "review_ratings": reviewRatings})
+	responseMetrics, err := models.GetResponseMetrics(context.Background(), productID, consts.PlatformType(platform), consts.TimePeriodType(timePeriod))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get response metrics", "details": err.Error()})
+		return
+	}
+
+	sentimentCategories, err := services.SentimentCategoriesForProduct(context.Background(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get sentiment categories", "details": err.Error()})
+		return
+	}
+
+	anomalies, err := models.GetReviewAnomalies(context.Background(), productID, consts.PlatformType(platform), consts.TimePeriodType(timePeriod))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get review anomalies", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats, "review_ratings": reviewRatings, "response_metrics": responseMetrics, "sentiment_categories": sentimentCategories, "anomalies": anomalies})
+}
+
+type UpdateBrandToneBody struct {
+	Tone       string `json:"tone" validate:"min=1,max=255"`
+	Guidelines string `json:"guidelines" validate:"max=2000"`
+	Signature  string `json:"signature" validate:"max=255"`
+}
+
+func HandlerGetBrandTone(c *gin.Context) {
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := models.GetBrandToneSettingsByProductID(context.Background(), product.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No brand tone settings for product"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch brand tone settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func HandlerUpdateBrandTone(c *gin.Context) {
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var body UpdateBrandToneBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := models.BrandToneSettings{
+		ProductID:  product.ID,
+		Tone:       body.Tone,
+		Guidelines: body.Guidelines,
+		Signature:  body.Signature,
+	}
+
+	if err := models.UpsertBrandToneSettings(context.Background(), &settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update brand tone settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+type SentimentCategoryBody struct {
+	Name           string   `json:"name" validate:"min=1,max=50"`
+	Description    string   `json:"description" validate:"max=500"`
+	ExamplePhrases []string `json:"example_phrases" validate:"max=10,dive,min=1,max=200"`
+}
+
+type UpdateSentimentCategoriesBody struct {
+	Categories []SentimentCategoryBody `json:"categories" validate:"min=1,max=10,dive"`
+}
+
+// HandlerGetSentimentCategories returns the product's sentiment categories,
+// or the defaults if it has not defined any
+func HandlerGetSentimentCategories(c *gin.Context) {
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	categories, err := services.SentimentCategoriesForProduct(context.Background(), product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch sentiment categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// HandlerUpdateSentimentCategories replaces the product's sentiment
+// categories. They apply from the next stats generation.
+func HandlerUpdateSentimentCategories(c *gin.Context) {
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var body UpdateSentimentCategoriesBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories := make([]*models.SentimentCategory, 0, len(body.Categories))
+	names := map[string]bool{}
+	for position, category := range body.Categories {
+		name := strings.TrimSpace(category.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category names cannot be blank"})
+			return
+		}
+		if names[strings.ToLower(name)] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Duplicate category %q", name)})
+			return
+		}
+		names[strings.ToLower(name)] = true
+
+		examplePhrases := category.ExamplePhrases
+		if examplePhrases == nil {
+			examplePhrases = []string{}
+		}
+
+		categories = append(categories, &models.SentimentCategory{
+			ProductID:      product.ID,
+			Name:           name,
+			Description:    category.Description,
+			ExamplePhrases: examplePhrases,
+			Position:       position,
+		})
+	}
+
+	if err := models.ReplaceSentimentCategories(context.Background(), product.ID, categories); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update sentiment categories"})
+		return
+	}
+
+	categoriesResponse, err := models.GetSentimentCategoriesByProductID(context.Background(), product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch sentiment categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categoriesResponse})
 }
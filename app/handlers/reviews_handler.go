@@ -5,10 +5,14 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/middleware"
 	"github.com/review-aggregator/review-api/app/models"
 	"github.com/review-aggregator/review-api/app/services"
 )
@@ -16,6 +20,14 @@ import (
 type InsertTrustpilotReviewsBody struct {
 	PlatformID uuid.UUID        `json:"platform_id"`
 	Reviews    []*models.Review `json:"reviews"`
+	// Mode and Cursor are sent by the scraper service after each page so an
+	// interrupted scrape can resume from the next one
+	Mode   consts.ScrapeModeType `json:"mode"`
+	Cursor *string               `json:"cursor"`
+	// BackfillJobID is set when the page belongs to a backfill job, and Done
+	// when the scraper service has reached the job's target
+	BackfillJobID *uuid.UUID `json:"backfill_job_id"`
+	Done          bool       `json:"done"`
 }
 
 func HandlerInsertTrustpilotReviews(c *gin.Context) {
@@ -26,7 +38,7 @@ func HandlerInsertTrustpilotReviews(c *gin.Context) {
 		return
 	}
 
-	if len(body.Reviews) == 0 {
+	if len(body.Reviews) == 0 && body.Cursor == nil && !body.Done {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No reviews provided"})
 		return
 	}
@@ -58,17 +70,77 @@ func HandlerInsertTrustpilotReviews(c *gin.Context) {
 		return
 	}
 
-	if err := models.CreateReviews(context.Background(), body.Reviews, platform.ID); err != nil {
-		fmt.Println("Error while inserting reviews", err)
+	if len(body.Reviews) > 0 {
+		if err := services.CheckReviewQuota(context.Background(), product, len(body.Reviews)); err != nil {
+			if body.BackfillJobID != nil {
+				models.FinishBackfillJob(context.Background(), *body.BackfillJobID, consts.BackfillStatusFailed, err)
+			}
+			if !respondQuotaError(c, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check review quota"})
+			}
+			return
+		}
+
+		// The cursor is only saved once the page is stored, so the scraper
+		// retries the page instead of skipping it
+		if err := models.CreateReviews(context.Background(), body.Reviews, platform.ID); err != nil {
+			fmt.Println("Error while inserting reviews", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not insert reviews"})
+			return
+		}
 	}
 
-	go func() {
-		services.GenerateProductStats(context.Background(), product.ID, product.UserID)
-	}()
+	if body.Cursor != nil {
+		if body.Mode == "" {
+			body.Mode = consts.ScrapeModeIncremental
+		}
+
+		err := models.UpsertScrapeCursor(context.Background(), &models.ScrapeCursor{
+			PlatformID: platform.ID,
+			Mode:       body.Mode,
+			Cursor:     *body.Cursor,
+		})
+		if err != nil {
+			fmt.Println("Error while saving scrape cursor", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save scrape cursor"})
+			return
+		}
+	}
+
+	if body.BackfillJobID != nil {
+		if err := services.RecordBackfillPage(context.Background(), *body.BackfillJobID, len(body.Reviews), body.Done); err != nil {
+			fmt.Println("Error while recording backfill page", err)
+		}
+	}
+
+	// Backfill pages only regenerate stats once the job is done, so a long
+	// backfill doesn't use up the day's stats generations
+	if (body.BackfillJobID == nil && len(body.Reviews) > 0) || body.Done {
+		go regenerateProductStats(context.Background(), product)
+	}
 
 	c.Status(http.StatusCreated)
 }
 
+// regenerateProductStats generates stats after new reviews were ingested,
+// counted against the product creator's daily stats generations
+func regenerateProductStats(ctx context.Context, product *models.Product) {
+	creator, err := models.GetUserByUserID(ctx, product.UserID)
+	if err != nil {
+		fmt.Println("Error while getting product creator", err)
+		return
+	}
+
+	if err := services.UseStatsGenerationQuota(ctx, creator, product.ID); err != nil {
+		fmt.Println("Skipping stats generation for product", product.ID, err)
+		return
+	}
+
+	if err := services.GenerateProductStats(ctx, product.ID, creator.ID); err != nil {
+		fmt.Println("Error while generating product stats", err)
+	}
+}
+
 func HandlerGetReviews(c *gin.Context) {
 	reviews, err := models.GetReviews(context.Background())
 	if err == sql.ErrNoRows {
@@ -121,3 +193,219 @@ func HandlerGetFormattedReviews(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"reviews": formattedReviews})
 }
+
+// negativeReviewMaxRating is the highest rating still treated as a negative review
+const negativeReviewMaxRating = 2
+
+func HandlerGetUnansweredNegativeReviews(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	productID, err := uuid.Parse(c.Param("product_id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	platform := consts.PlatformType(c.Query("platform"))
+	timePeriod := consts.TimePeriodType(c.DefaultQuery("time_period", string(consts.TimePeriodAllTime)))
+
+	reviews, err := models.GetUnansweredNegativeReviews(context.Background(), productID, contextUser.ID, platform, timePeriod, negativeReviewMaxRating)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+}
+
+func HandlerDraftReviewReply(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	reviewID, err := uuid.Parse(c.Param("review_id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	review, err := models.GetReviewByIDAndUserID(context.Background(), reviewID, contextUser.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get review"})
+		return
+	}
+
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := models.GetBrandToneSettingsByProductID(context.Background(), product.ID)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get brand tone settings"})
+		return
+	}
+
+	ctx := services.WithLLMAttribution(context.Background(), product.ID, contextUser.ID)
+	replyBody, err := services.GenerateReplyDraft(ctx, product, settings, review)
+	if err != nil {
+		fmt.Println("Error while generating reply draft", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not generate reply draft"})
+		return
+	}
+
+	draft := models.ReplyDraft{
+		ReviewID:  review.ID,
+		CreatedBy: contextUser.ID,
+		Body:      replyBody,
+		Status:    consts.ReplyDraftStatusDraft,
+		Model:     services.ReplyModel(),
+	}
+
+	if err := models.CreateReplyDraft(context.Background(), &draft); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save reply draft"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, draft)
+}
+
+func HandlerGetReviewReplyDrafts(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	reviewID, err := uuid.Parse(c.Param("review_id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.GetReviewByIDAndUserID(context.Background(), reviewID, contextUser.ID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get review"})
+		return
+	}
+
+	drafts, err := models.GetReplyDraftsByReviewID(context.Background(), reviewID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get reply drafts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"drafts": drafts})
+}
+
+type UpdateReplyDraftBody struct {
+	Body   string                      `json:"body"`
+	Status consts.ReplyDraftStatusType `json:"status" validate:"oneof=draft approved posted"`
+}
+
+// replyDraftTransitions lists the statuses a draft may move to from each status
+var replyDraftTransitions = map[consts.ReplyDraftStatusType][]consts.ReplyDraftStatusType{
+	consts.ReplyDraftStatusDraft:    {consts.ReplyDraftStatusDraft, consts.ReplyDraftStatusApproved},
+	consts.ReplyDraftStatusApproved: {consts.ReplyDraftStatusDraft, consts.ReplyDraftStatusApproved, consts.ReplyDraftStatusPosted},
+	consts.ReplyDraftStatusPosted:   {},
+}
+
+func HandlerUpdateReviewReplyDraft(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	reviewID, err := uuid.Parse(c.Param("review_id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	draftID, err := uuid.Parse(c.Param("draft_id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var body UpdateReplyDraftBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := models.GetReviewByIDAndUserID(context.Background(), reviewID, contextUser.ID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get review"})
+		return
+	}
+
+	draft, err := models.GetReplyDraftByID(context.Background(), draftID, reviewID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reply draft not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get reply draft"})
+		return
+	}
+
+	if !slices.Contains(replyDraftTransitions[draft.Status], body.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot change reply draft from %s to %s", draft.Status, body.Status)})
+		return
+	}
+
+	// Only drafts can be edited, approved text is what gets posted
+	if body.Body != "" && body.Body != draft.Body {
+		if draft.Status != consts.ReplyDraftStatusDraft {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only drafts can be edited"})
+			return
+		}
+		draft.Body = body.Body
+	}
+
+	now := time.Now()
+	switch body.Status {
+	case consts.ReplyDraftStatusDraft:
+		draft.ApprovedBy = nil
+		draft.ApprovedAt = nil
+	case consts.ReplyDraftStatusApproved:
+		if draft.Status != consts.ReplyDraftStatusApproved {
+			draft.ApprovedBy = &contextUser.ID
+			draft.ApprovedAt = &now
+		}
+	case consts.ReplyDraftStatusPosted:
+		draft.PostedAt = &now
+	}
+	draft.Status = body.Status
+
+	if err := models.UpdateReplyDraft(context.Background(), draft); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update reply draft"})
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/middleware"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/services"
+)
+
+var llmUsagePeriods = []string{"day", "week", "month"}
+
+// defaultLLMUsageDays is how far back LLM usage summaries go without ?since
+const defaultLLMUsageDays = 30
+
+// respondQuotaError writes the response for a services.QuotaError and
+// reports whether err was one
+func respondQuotaError(c *gin.Context, err error) bool {
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	if quotaErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(quotaErr.RetryAfter.Seconds()))))
+	}
+
+	c.JSON(quotaErr.Status, gin.H{
+		"error":    quotaErr.Error(),
+		"resource": quotaErr.Resource,
+		"plan":     quotaErr.Plan,
+		"limit":    quotaErr.Limit,
+		"used":     quotaErr.Used,
+	})
+	return true
+}
+
+func HandlerGetUsage(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := services.GetUsage(context.Background(), &contextUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// respondLLMUsageSummary writes the LLM usage summary for the ?period and
+// ?since (YYYY-MM-DD) query parameters, narrowed to a product and/or user
+func respondLLMUsageSummary(c *gin.Context, productID, userID *uuid.UUID) {
+	period := c.DefaultQuery("period", "day")
+	if !slices.Contains(llmUsagePeriods, period) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be one of day, week or month"})
+		return
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -defaultLLMUsageDays)
+	if sinceParam := c.Query("since"); sinceParam != "" {
+		parsed, err := time.Parse(time.DateOnly, sinceParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a date formatted YYYY-MM-DD"})
+			return
+		}
+		since = parsed
+	}
+
+	summary, err := models.GetLLMUsageSummary(context.Background(), productID, userID, period, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch LLM usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"period": period, "since": since, "usage": summary})
+}
+
+// HandlerGetLLMUsage summarizes the LLM calls the user triggered
+func HandlerGetLLMUsage(c *gin.Context) {
+	contextUser, err := middleware.GetContextUser(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	respondLLMUsageSummary(c, nil, &contextUser.ID)
+}
+
+// HandlerGetProductLLMUsage summarizes every LLM call made for the product,
+// including scheduled ones
+func HandlerGetProductLLMUsage(c *gin.Context) {
+	product, err := middleware.GetContextProduct(c)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	respondLLMUsageSummary(c, &product.ID, nil)
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
+	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
 	"github.com/review-aggregator/review-api/app/middleware"
 	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,9 +27,12 @@ func HandlerGetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-type ClerkUserCreatedWebhook struct {
+// ClerkUserWebhook is the payload of Clerk's user.created, user.updated and
+// user.deleted events. Deleted events only carry Data.ID and Data.Deleted.
+type ClerkUserWebhook struct {
 	Data struct {
 		Birthday       string `json:"birthday"`
+		Deleted        bool   `json:"deleted"`
 		CreatedAt      int64  `json:"created_at"`
 		EmailAddresses []struct {
 			EmailAddress string        `json:"email_address"`
@@ -74,30 +81,128 @@ type ClerkUserCreatedWebhook struct {
 	Type      string `json:"type"`
 }
 
+const (
+	clerkEventUserCreated = "user.created"
+	clerkEventUserUpdated = "user.updated"
+	clerkEventUserDeleted = "user.deleted"
+)
+
 func HandlerSignUpClerkWebhook(c *gin.Context) {
-	var body ClerkUserCreatedWebhook
+	rawBody, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
 
-	if err := c.ShouldBindJSON(&body); err != nil {
+	if err := utils.VerifySvixWebhook(config.Config.ClerkWebhookSecret, c.Request.Header, rawBody); err != nil {
+		log.Warn("Rejected clerk webhook: ", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	var body ClerkUserWebhook
+	if err := json.Unmarshal(rawBody, &body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	userUUID := uuid.New()
-	var email string
-	if body.Data.EmailAddresses != nil && len(body.Data.EmailAddresses) > 0 && body.Data.EmailAddresses[0].EmailAddress != "" {
-		email = body.Data.EmailAddresses[0].EmailAddress
+	// Svix retries deliveries, so each message ID is only processed once
+	messageID := c.GetHeader("svix-id")
+	isNew, err := models.RecordWebhookEvent(context.Background(), messageID, "clerk", body.Type)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not record webhook"})
+		return
+	}
+	if !isNew {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	switch body.Type {
+	case clerkEventUserCreated:
+		err = createClerkUser(body)
+	case clerkEventUserUpdated:
+		err = updateClerkUser(body)
+	case clerkEventUserDeleted:
+		err = deleteClerkUser(body)
+	default:
+		log.Info("Ignoring clerk webhook event: ", body.Type)
+	}
+
+	if err != nil {
+		log.Error("Error while handling clerk webhook "+body.Type, err)
+		// Forget the message so Svix's retry is processed again
+		models.DeleteWebhookEvent(context.Background(), messageID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not process webhook"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// primaryEmail returns the user's primary email address, falling back to the first one
+func (w ClerkUserWebhook) primaryEmail() string {
+	for _, emailAddress := range w.Data.EmailAddresses {
+		if emailAddress.ID == w.Data.PrimaryEmailAddressID {
+			return emailAddress.EmailAddress
+		}
+	}
+
+	if len(w.Data.EmailAddresses) > 0 {
+		return w.Data.EmailAddresses[0].EmailAddress
 	}
 
+	return ""
+}
+
+func (w ClerkUserWebhook) fullName() string {
+	return strings.TrimSpace(w.Data.FirstName + " " + w.Data.LastName)
+}
+
+func createClerkUser(body ClerkUserWebhook) error {
 	user := models.User{
-		ID:      userUUID,
+		ID:      uuid.New(),
 		ClerkID: body.Data.ID,
-		Name:    body.Data.FirstName + " " + body.Data.LastName,
-		Email:   email,
+		Name:    body.fullName(),
+		Email:   body.primaryEmail(),
 	}
 
-	fmt.Println(user)
 	if err := models.CreateUser(context.Background(), &user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
-		return
+		return err
+	}
+
+	// Not fatal, the organization is created on first use otherwise
+	if _, err := defaultOrganization(context.Background(), &user); err != nil {
+		log.Error("Error while creating personal organization", err)
 	}
+
+	return nil
+}
+
+func updateClerkUser(body ClerkUserWebhook) error {
+	user, err := models.GetUserByClerkID(context.Background(), body.Data.ID)
+	if err == sql.ErrNoRows {
+		// Deleted users are not brought back by late update events
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	user.Name = body.fullName()
+	user.Email = body.primaryEmail()
+
+	return models.UpdateUser(context.Background(), user)
+}
+
+func deleteClerkUser(body ClerkUserWebhook) error {
+	user, err := models.GetUserByClerkID(context.Background(), body.Data.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return models.SoftDeleteUser(context.Background(), user.ID)
 }
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/utils"
+)
+
+const (
+	// contextAPIKey holds the *models.APIKey of requests authenticated with one
+	contextAPIKey = "api_key"
+	// contextScopeChecked is set by RequireScope once the key's scope is checked
+	contextScopeChecked = "api_key_scope_checked"
+)
+
+// ErrScopeNotChecked is returned by GetContextUser for API key requests on
+// routes without a RequireScope
+var ErrScopeNotChecked = errors.New("api key scope not checked")
+
+// APIKeyAuthenticator accepts personal API keys created by users
+type APIKeyAuthenticator struct{}
+
+func (APIKeyAuthenticator) Authenticate(c *gin.Context) (*models.User, error) {
+	key := bearerToken(c)
+	if !strings.HasPrefix(key, utils.APIKeyPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	apiKey, err := models.GetActiveAPIKeyByHash(c, utils.HashAPIKey(key))
+	if err == sql.ErrNoRows {
+		return nil, unauthorized("Invalid or expired API key")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := models.GetUserByUserID(c, apiKey.UserID)
+	if err == sql.ErrNoRows {
+		return nil, unauthorized("User not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := models.TouchAPIKey(c, apiKey.ID); err != nil {
+		log.Error("Error while recording api key use", err)
+	}
+
+	c.Set(contextAPIKey, apiKey)
+	return user, nil
+}
+
+// RequireScope rejects API key requests whose key lacks scope. Requests
+// authenticated with a session token have every scope. API keys are refused
+// on routes without a RequireScope, see GetContextUser.
+func RequireScope(scope consts.APIKeyScopeType) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey, ok := c.Get(contextAPIKey); ok {
+			if !slices.Contains(apiKey.(*models.APIKey).Scopes, string(scope)) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing scope " + string(scope)})
+				c.Abort()
+				return
+			}
+			c.Set(contextScopeChecked, true)
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects requests authenticated with an API key, for routes
+// such as key management that only a signed in user may use
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(contextAPIKey); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this route"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
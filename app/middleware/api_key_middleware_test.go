@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+// stubKeyAuthenticator authenticates every request as an API key with scopes
+type stubKeyAuthenticator struct {
+	scopes pq.StringArray
+}
+
+func (a stubKeyAuthenticator) Authenticate(c *gin.Context) (*models.User, error) {
+	c.Set(contextAPIKey, &models.APIKey{ID: uuid.New(), Scopes: a.scopes})
+	return &models.User{}, nil
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Handlers read the caller like the real ones do
+	ok := func(c *gin.Context) {
+		if _, err := GetContextUser(c); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	auth := AuthMiddleware(stubKeyAuthenticator{scopes: pq.StringArray{string(consts.APIKeyScopeReadReviews)}})
+
+	router := gin.New()
+	router.GET("/unscoped", auth, ok)
+	router.GET("/read", auth, RequireScope(consts.APIKeyScopeReadReviews), ok)
+	router.GET("/write", auth, RequireScope(consts.APIKeyScopeWriteProducts), ok)
+	router.GET("/session", auth, RequireSession(), ok)
+	// Scope checks are found however the middleware is wrapped
+	router.GET("/wrapped", auth, func(c *gin.Context) { RequireScope(consts.APIKeyScopeReadReviews)(c) }, ok)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/unscoped", http.StatusForbidden},
+		{"/read", http.StatusOK},
+		{"/write", http.StatusForbidden},
+		{"/session", http.StatusForbidden},
+		{"/wrapped", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer rk_test")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+// stubSessionAuthenticator authenticates every request as a signed in user
+type stubSessionAuthenticator struct{}
+
+func (stubSessionAuthenticator) Authenticate(c *gin.Context) (*models.User, error) {
+	return &models.User{ID: uuid.New()}, nil
+}
+
+func TestSessionsSkipScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/unscoped", AuthMiddleware(stubSessionAuthenticator{}), func(c *gin.Context) {
+		if _, err := GetContextUser(c); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/unscoped", nil)
+	req.Header.Set("Authorization", "Bearer session-token")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
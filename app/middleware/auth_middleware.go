@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
 	"github.com/review-aggregator/review-api/app/models"
 	"github.com/review-aggregator/review-api/app/utils"
 
@@ -17,66 +20,167 @@ var (
 	log = utils.CreateLogger()
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// no credentials of its kind, so the next authenticator in the chain is tried
+var ErrNoCredentials = errors.New("no credentials")
+
+// AuthError is an authentication failure with the status to respond with
+type AuthError struct {
+	Status  int
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+func unauthorized(message string) *AuthError {
+	return &AuthError{Status: http.StatusUnauthorized, Message: message}
+}
+
+// Authenticator resolves the user behind a request for one kind of credential
+type Authenticator interface {
+	Authenticate(c *gin.Context) (*models.User, error)
+}
+
+// DefaultAuthenticators is the chain used for user-facing routes. First-party
+// tokens are only accepted when JWT_SECRET is set.
+func DefaultAuthenticators() []Authenticator {
+	authenticators := []Authenticator{
+		APIKeyAuthenticator{},
+		ClerkAuthenticator{},
+	}
+	if config.Config.JWTSecret != "" {
+		authenticators = append(authenticators, FirstPartyJWTAuthenticator{Secret: config.Config.JWTSecret})
+	}
+
+	return authenticators
+}
+
+// AuthMiddleware authenticates the request with the first authenticator that
+// recognises its credentials and stores the user in the context. It uses
+// DefaultAuthenticators when none are given.
+func AuthMiddleware(authenticators ...Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.Request.Header.Get("Authorization")
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
+		chain := authenticators
+		if len(chain) == 0 {
+			chain = DefaultAuthenticators()
 		}
 
-		parsedToken, err := utils.ValidateJWT(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		if bearerToken(c) == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
 
-		if !parsedToken.Valid {
-			c.AbortWithStatus(http.StatusUnauthorized)
+		for _, authenticator := range chain {
+			user, err := authenticator.Authenticate(c)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+
+			var authErr *AuthError
+			if errors.As(err, &authErr) {
+				c.JSON(authErr.Status, gin.H{"error": authErr.Message})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				log.Error("Error while authenticating request", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+
+			c.Set("user", user)
+			c.Next()
 			return
 		}
 
-		claims, ok := parsedToken.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unsupported credentials"})
+		c.Abort()
+	}
+}
 
-		userIDstring, ok := claims["user_id"].(string)
-		if !ok {
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+// bearerToken returns the token from the Authorization header. The
+// "Bearer " prefix is optional for compatibility with older clients.
+func bearerToken(c *gin.Context) string {
+	header := strings.TrimSpace(c.GetHeader("Authorization"))
+	if token, found := strings.CutPrefix(header, "Bearer "); found {
+		return strings.TrimSpace(token)
+	}
 
-		userID, err := uuid.Parse(userIDstring)
-		if err != nil {
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+	return header
+}
 
-		user, err := models.GetUserByUserID(c, userID)
-		if err == sql.ErrNoRows {
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
-		if err != nil {
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+// tokenAlgorithm returns the alg header of a JWT without verifying it, or ""
+// if the token is not a JWT
+func tokenAlgorithm(tokenString string) string {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
 
-		contextUser := models.User{
-			ID:    user.ID,
-			Email: user.Email,
-		}
+	alg, _ := token.Header["alg"].(string)
+	return alg
+}
+
+// FirstPartyJWTAuthenticator accepts HS256 tokens issued by
+// utils.GenerateAuthToken and signed with Secret
+type FirstPartyJWTAuthenticator struct {
+	Secret string
+}
+
+func (a FirstPartyJWTAuthenticator) Authenticate(c *gin.Context) (*models.User, error) {
+	tokenString := bearerToken(c)
+	if !strings.HasPrefix(tokenAlgorithm(tokenString), "HS") {
+		return nil, ErrNoCredentials
+	}
+
+	// An empty key would verify tokens anyone can sign
+	if a.Secret == "" {
+		return nil, unauthorized("Invalid token")
+	}
+
+	parsedToken, err := utils.ValidateJWT(a.Secret, tokenString)
+	if err != nil {
+		return nil, unauthorized("Invalid token")
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, unauthorized("Invalid token")
+	}
+
+	userIDstring, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, unauthorized("Invalid token")
+	}
 
-		c.Set("user", contextUser)
-		c.Next()
+	userID, err := uuid.Parse(userIDstring)
+	if err != nil {
+		return nil, unauthorized("Invalid token")
 	}
+
+	user, err := models.GetUserByUserID(c, userID)
+	if err == sql.ErrNoRows {
+		return nil, unauthorized("User not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
+// GetContextUser returns the authenticated user. API keys are denied by
+// default: on routes without a RequireScope it responds 403 and returns
+// ErrScopeNotChecked, so a route added without a scope accepts no keys.
 func GetContextUser(c *gin.Context) (models.User, error) {
+	if _, ok := c.Get(contextAPIKey); ok && !c.GetBool(contextScopeChecked) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this route"})
+		return models.User{}, ErrScopeNotChecked
+	}
+
 	contextUserMap, exists := c.Get("user")
 	log.Debug("contextUserMap type:", fmt.Sprintf("%T", contextUserMap))
 	log.Debug("contextUserMap value:", contextUserMap)
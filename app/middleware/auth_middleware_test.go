@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/utils"
+)
+
+func TestFirstPartyTokensNeedJWTSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := config.Config
+	config.Config.JWTSecret = ""
+	t.Cleanup(func() { config.Config = previous })
+
+	router := gin.New()
+	router.GET("/", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Signed with the empty secret an unconfigured deployment would verify against
+	token, err := utils.GenerateAuthToken("", utils.AuthTokenClaims{UserID: uuid.New()}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateAuthToken: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestFirstPartyAuthenticatorRefusesEmptySecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	token, err := utils.GenerateAuthToken("", utils.AuthTokenClaims{UserID: uuid.New()}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateAuthToken: %v", err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("Authorization", "Bearer "+token)
+
+	if _, err := (FirstPartyJWTAuthenticator{}).Authenticate(c); err == nil || err == ErrNoCredentials {
+		t.Errorf("Authenticate error = %v, want an authentication error", err)
+	}
+}
@@ -1,91 +1,148 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rsa"
 	"database/sql"
 	"fmt"
-	"net/http"
-	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/review-aggregator/review-api/app/config"
 	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/utils"
 )
 
-// ClerkMiddleware validates the Clerk auth token
-func ClerkMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
-			c.Abort()
-			return
-		}
+// ClerkAuthenticator accepts RS256 session tokens issued by Clerk
+type ClerkAuthenticator struct{}
 
-		// Extract token from "Bearer <token>"
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization format"})
-			c.Abort()
-			return
-		}
+func (ClerkAuthenticator) Authenticate(c *gin.Context) (*models.User, error) {
+	tokenString := bearerToken(c)
+	if !strings.HasPrefix(tokenAlgorithm(tokenString), "RS") {
+		return nil, ErrNoCredentials
+	}
 
-		tokenString := tokenParts[1]
+	// Verify the token
+	claims, err := verifyClerkToken(c, tokenString)
+	if err != nil {
+		return nil, unauthorized(err.Error())
+	}
 
-		// Verify the token
-		claims, err := verifyClerkToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
-			return
-		}
+	clerkUserID, ok := claims["sub"].(string)
+	if !ok {
+		return nil, unauthorized("Invalid token subject")
+	}
 
-		clerkUserID, ok := claims["sub"].(string)
-		if !ok {
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+	user, err := models.GetUserByClerkID(c, clerkUserID)
+	if err == sql.ErrNoRows {
+		return nil, unauthorized("User not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
 
-		user, err := models.GetUserByClerkID(c, clerkUserID)
-		if err == sql.ErrNoRows {
-			c.AbortWithStatus(http.StatusUnauthorized)
+var (
+	clerkJWKS     *utils.JWKSClient
+	clerkPEMKey   *rsa.PublicKey
+	clerkKeysOnce sync.Once
+	clerkKeysErr  error
+)
+
+// SetClerkJWKSURL points Clerk token verification at a different JWKS
+// endpoint, such as a testutil.JWKSStub in tests
+func SetClerkJWKSURL(url string) {
+	clerkKeysOnce.Do(func() {})
+	clerkJWKS = utils.NewJWKSClient(url)
+	clerkKeysErr = nil
+}
+
+func loadClerkKeys() error {
+	clerkKeysOnce.Do(func() {
+		if config.Config.ClerkJWKSURL != "" {
+			clerkJWKS = utils.NewJWKSClient(config.Config.ClerkJWKSURL)
 			return
 		}
-		if err != nil {
-			c.AbortWithStatus(http.StatusInternalServerError)
+
+		if config.Config.ClerkPublicKey == "" {
+			clerkKeysErr = fmt.Errorf("missing Clerk JWKS URL or public key")
 			return
 		}
 
-		c.Set("user", user)
-		c.Next()
-	}
+		clerkPEMKey, clerkKeysErr = jwt.ParseRSAPublicKeyFromPEM([]byte(config.Config.ClerkPublicKey))
+	})
+
+	return clerkKeysErr
 }
 
-// verifyClerkToken verifies and parses the JWT token
-func verifyClerkToken(tokenString string) (jwt.MapClaims, error) {
-	clerkJWTKey := os.Getenv("CLERK_JWT_PUBLIC_KEY")
-	if clerkJWTKey == "" {
-		return nil, fmt.Errorf("missing Clerk public key")
+// verifyClerkToken verifies the token's signature against Clerk's keys and
+// checks its expiry, issuer and authorized party
+func verifyClerkToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	if err := loadClerkKeys(); err != nil {
+		return nil, err
 	}
 
-	// Parse and verify the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	// Time based claims are checked below with clock skew tolerance
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		// Load Clerk public key
-		return jwt.ParseRSAPublicKeyFromPEM([]byte(clerkJWTKey))
-	})
+		if clerkJWKS == nil {
+			return clerkPEMKey, nil
+		}
 
+		kid, _ := token.Header["kid"].(string)
+		return clerkJWKS.Key(ctx, kid)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Validate claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if err := validateClerkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func validateClerkClaims(claims jwt.MapClaims) error {
+	skew := int64(config.Config.ClerkClockSkewSeconds)
+	now := time.Now().Unix()
+
+	// Clerk session tokens always carry exp
+	if _, ok := claims["exp"]; !ok || !claims.VerifyExpiresAt(now-skew, true) {
+		return fmt.Errorf("token is expired")
+	}
+	if !claims.VerifyNotBefore(now+skew, false) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	if !claims.VerifyIssuedAt(now+skew, false) {
+		return fmt.Errorf("token used before issued")
+	}
+
+	if config.Config.ClerkIssuer != "" && !claims.VerifyIssuer(config.Config.ClerkIssuer, true) {
+		return fmt.Errorf("invalid token issuer")
+	}
+
+	// azp is only present on tokens issued to browsers, and must then be one of our origins
+	if azp, ok := claims["azp"].(string); ok && len(config.Config.ClerkAuthorizedParties) > 0 {
+		if !slices.Contains(config.Config.ClerkAuthorizedParties, azp) {
+			return fmt.Errorf("invalid token authorized party")
+		}
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return nil
 }
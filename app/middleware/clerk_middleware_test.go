@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/internal/testutil"
+)
+
+const (
+	testClerkIssuer = "https://clerk.example.com"
+	testClerkOrigin = "https://app.example.com"
+)
+
+func setupClerk(t *testing.T) *testutil.JWKSStub {
+	t.Helper()
+
+	stub, err := testutil.NewJWKSStub()
+	if err != nil {
+		t.Fatalf("NewJWKSStub: %v", err)
+	}
+	t.Cleanup(stub.Close)
+
+	previous := config.Config
+	config.Config.ClerkIssuer = testClerkIssuer
+	config.Config.ClerkAuthorizedParties = []string{testClerkOrigin}
+	config.Config.ClerkClockSkewSeconds = 5
+	t.Cleanup(func() { config.Config = previous })
+
+	SetClerkJWKSURL(stub.URL())
+
+	return stub
+}
+
+func TestVerifyClerkToken(t *testing.T) {
+	stub := setupClerk(t)
+	now := time.Now().Unix()
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub": "user_2abc",
+			"iss": testClerkIssuer,
+			"azp": testClerkOrigin,
+			"iat": now,
+			"nbf": now,
+			"exp": now + 60,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(claims jwt.MapClaims)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(jwt.MapClaims) {}},
+		{name: "no azp", modify: func(claims jwt.MapClaims) { delete(claims, "azp") }},
+		{name: "wrong issuer", modify: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }, wantErr: true},
+		{name: "missing issuer", modify: func(claims jwt.MapClaims) { delete(claims, "iss") }, wantErr: true},
+		{name: "unauthorized party", modify: func(claims jwt.MapClaims) { claims["azp"] = "https://evil.example.com" }, wantErr: true},
+		{name: "missing exp", modify: func(claims jwt.MapClaims) { delete(claims, "exp") }, wantErr: true},
+		{name: "expired within skew", modify: func(claims jwt.MapClaims) { claims["exp"] = now - 3 }},
+		{name: "expired beyond skew", modify: func(claims jwt.MapClaims) { claims["exp"] = now - 30 }, wantErr: true},
+		{name: "not before within skew", modify: func(claims jwt.MapClaims) { claims["nbf"] = now + 3 }},
+		{name: "not before beyond skew", modify: func(claims jwt.MapClaims) { claims["nbf"] = now + 30 }, wantErr: true},
+		{name: "issued in the future", modify: func(claims jwt.MapClaims) { claims["iat"] = now + 30 }, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := validClaims()
+			test.modify(claims)
+
+			token, err := stub.SignToken(claims)
+			if err != nil {
+				t.Fatalf("SignToken: %v", err)
+			}
+
+			_, err = verifyClerkToken(context.Background(), token)
+			if (err != nil) != test.wantErr {
+				t.Errorf("verifyClerkToken error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyClerkTokenRejectsOtherSigners(t *testing.T) {
+	setupClerk(t)
+
+	other, err := testutil.NewJWKSStub()
+	if err != nil {
+		t.Fatalf("NewJWKSStub: %v", err)
+	}
+	defer other.Close()
+
+	token, err := other.SignToken(jwt.MapClaims{"sub": "user_2abc", "iss": testClerkIssuer, "exp": time.Now().Unix() + 60})
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+
+	// The other stub's kid is also "stub-key-1" but its key differs
+	if _, err := verifyClerkToken(context.Background(), token); err == nil {
+		t.Error("verifyClerkToken accepted a token signed with another key")
+	}
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/utils"
+)
+
+// signatureMaxAge is how far a signed request's timestamp may drift from now
+const signatureMaxAge = 5 * time.Minute
+
+// InternalAuthMiddleware authenticates service-to-service calls such as the
+// scraper service's callbacks. A request is accepted when it is signed with
+// INTERNAL_HMAC_SECRET (see utils.SignRequest) or, unless
+// INTERNAL_REQUIRE_SIGNATURE is set, when it carries INTERNAL_AUTH_TOKEN as a
+// bearer token. Bearer tokens are refused while INTERNAL_AUTH_TOKEN is unset.
+func InternalAuthMiddleware() gin.HandlerFunc {
+	nonces := newNonceCache(2 * signatureMaxAge)
+
+	return func(c *gin.Context) {
+		if c.GetHeader(utils.HeaderSignature) != "" {
+			if err := verifySignedRequest(c, nonces); err != "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err})
+				c.Abort()
+				return
+			}
+
+			c.Next()
+			return
+		}
+
+		if config.Config.InternalRequireSignature {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Request signature required"})
+			c.Abort()
+			return
+		}
+
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || config.Config.InternalAuthToken == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(config.Config.InternalAuthToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid internal token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// verifySignedRequest checks the signature headers and returns an error
+// message for the client, or "" when the request is valid
+func verifySignedRequest(c *gin.Context, nonces *nonceCache) string {
+	if config.Config.InternalHMACSecret == "" {
+		return "Request signing is not configured"
+	}
+
+	timestamp := c.GetHeader(utils.HeaderSignatureTimestamp)
+	nonce := c.GetHeader(utils.HeaderSignatureNonce)
+	if timestamp == "" || nonce == "" {
+		return "Missing signature timestamp or nonce"
+	}
+
+	unixSeconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "Invalid signature timestamp"
+	}
+
+	if age := time.Since(time.Unix(unixSeconds, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return "Signature timestamp outside allowed window"
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		body, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "Could not read request body"
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if !utils.VerifyRequestSignature(config.Config.InternalHMACSecret, c.GetHeader(utils.HeaderSignature), timestamp, nonce, c.Request.Method, c.Request.URL.RequestURI(), body) {
+		return "Invalid request signature"
+	}
+
+	// Checked last so unsigned garbage can't fill the cache
+	if !nonces.add(nonce) {
+		return "Request already processed"
+	}
+
+	return ""
+}
+
+// nonceCache remembers nonces for ttl to reject replayed requests. It is
+// per-process, which is enough while the API runs as a single instance.
+type nonceCache struct {
+	mutex     sync.Mutex
+	ttl       time.Duration
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{ttl: ttl, seen: map[string]time.Time{}}
+}
+
+// add records nonce and reports false if it was already seen within ttl
+func (n *nonceCache) add(nonce string) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(n.lastPrune) > n.ttl {
+		for seenNonce, expiresAt := range n.seen {
+			if now.After(expiresAt) {
+				delete(n.seen, seenNonce)
+			}
+		}
+		n.lastPrune = now
+	}
+
+	if expiresAt, ok := n.seen[nonce]; ok && now.Before(expiresAt) {
+		return false
+	}
+
+	n.seen[nonce] = now.Add(n.ttl)
+	return true
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/policy"
+)
+
+// contextProduct holds the *models.Product authorized by ProductPolicy or ReviewPolicy
+const contextProduct = "product"
+
+// ProductPolicy loads the :product_id product for the context user and
+// rejects the request unless their role allows action. It must run after
+// AuthMiddleware.
+func ProductPolicy(action policy.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := uuid.Parse(c.Param("product_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			c.Abort()
+			return
+		}
+
+		authorizeProduct(c, action, func(user models.User) (*models.Product, error) {
+			return models.GetProductByIDAndUserID(c, productID, user.ID)
+		})
+	}
+}
+
+// ReviewPolicy is ProductPolicy for routes addressing a review by :review_id,
+// authorizing against the product the review belongs to
+func ReviewPolicy(action policy.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviewID, err := uuid.Parse(c.Param("review_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			c.Abort()
+			return
+		}
+
+		authorizeProduct(c, action, func(user models.User) (*models.Product, error) {
+			return models.GetProductByReviewIDAndUserID(c, reviewID, user.ID)
+		})
+	}
+}
+
+func authorizeProduct(c *gin.Context, action policy.Action, getProduct func(models.User) (*models.Product, error)) {
+	user, err := GetContextUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Abort()
+		return
+	}
+
+	product, err := getProduct(user)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch product"})
+		c.Abort()
+		return
+	}
+
+	if !policy.Can(product.Role, action) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role does not allow " + string(action)})
+		c.Abort()
+		return
+	}
+
+	c.Set(contextProduct, product)
+	c.Next()
+}
+
+// GetContextProduct returns the product authorized by ProductPolicy or ReviewPolicy
+func GetContextProduct(c *gin.Context) (*models.Product, error) {
+	product, ok := c.Get(contextProduct)
+	if !ok {
+		return nil, fmt.Errorf("product not found in gin.Context")
+	}
+
+	return product.(*models.Product), nil
+}
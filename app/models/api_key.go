@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	queryInsertAPIKey = `
+	INSERT INTO api_keys(id, user_id, name, prefix, key_hash, scopes, expires_at, created_at, updated_at)
+	VALUES(:id, :user_id, :name, :prefix, :key_hash, :scopes, :expires_at, NOW(), NOW())
+	RETURNING created_at`
+
+	queryGetAPIKeysByUserID = `
+	SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at, k.revoked_at, k.created_at, k.updated_at
+	FROM api_keys k
+	WHERE k.user_id = :user_id
+	ORDER BY k.created_at DESC`
+
+	queryGetActiveAPIKeyByHash = `
+	SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at, k.revoked_at, k.created_at, k.updated_at
+	FROM api_keys k
+	WHERE k.key_hash = :key_hash
+	AND k.revoked_at IS NULL
+	AND (k.expires_at IS NULL OR k.expires_at > NOW())`
+
+	queryRevokeAPIKey = `
+	UPDATE api_keys
+	SET revoked_at = NOW(), updated_at = NOW()
+	WHERE id = :id AND user_id = :user_id AND revoked_at IS NULL`
+
+	// Only written once a minute so busy keys don't update the row on every request
+	queryTouchAPIKey = `
+	UPDATE api_keys
+	SET last_used_at = NOW()
+	WHERE id = :id AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+)
+
+type APIKey struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	UserID     uuid.UUID      `json:"user_id" db:"user_id"`
+	Name       string         `json:"name" db:"name"`
+	Prefix     string         `json:"prefix" db:"prefix"`
+	KeyHash    string         `json:"-" db:"key_hash"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time     `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time     `json:"revoked_at" db:"revoked_at"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+func CreateAPIKey(ctx context.Context, apiKey *APIKey) error {
+	if apiKey.ID == uuid.Nil {
+		apiKey.ID = uuid.New()
+	}
+
+	err := db.NamedExecContextReturnID(ctx, queryInsertAPIKey, apiKey, &apiKey.CreatedAt)
+	if err != nil {
+		log.Error("Error while creating api key", err)
+		return err
+	}
+	apiKey.UpdatedAt = apiKey.CreatedAt
+
+	return nil
+}
+
+func GetAPIKeysByUserID(ctx context.Context, userID uuid.UUID) ([]*APIKey, error) {
+	apiKeys := make([]*APIKey, 0)
+
+	err := db.NamedSelectContext(ctx, &apiKeys, queryGetAPIKeysByUserID, map[string]interface{}{
+		"user_id": userID,
+	})
+	if err != nil {
+		log.Error("Error while fetching api keys by user id", err)
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+// GetActiveAPIKeyByHash returns the key with the given hash if it is neither revoked nor expired
+func GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	var apiKey APIKey
+
+	err := db.NamedGetContext(ctx, &apiKey, queryGetActiveAPIKeyByHash, map[string]interface{}{
+		"key_hash": keyHash,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching api key by hash", err)
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+// RevokeAPIKey revokes one of the user's keys and returns sql.ErrNoRows if
+// there is no such active key
+func RevokeAPIKey(ctx context.Context, apiKeyID, userID uuid.UUID) error {
+	result, err := db.NamedExecContext(ctx, queryRevokeAPIKey, map[string]interface{}{
+		"id":      apiKeyID,
+		"user_id": userID,
+	})
+	if err != nil {
+		log.Error("Error while revoking api key", err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func TouchAPIKey(ctx context.Context, apiKeyID uuid.UUID) error {
+	_, err := db.NamedExecContext(ctx, queryTouchAPIKey, map[string]interface{}{
+		"id": apiKeyID,
+	})
+	if err != nil {
+		log.Error("Error while updating api key last used", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+const (
+	queryInsertBackfillJob = `
+	INSERT INTO backfill_jobs(id, platform_id, status, target_reviews, target_since, page_delay_seconds, created_at, updated_at)
+	VALUES(:id, :platform_id, :status, :target_reviews, :target_since, :page_delay_seconds, NOW(), NOW())`
+
+	queryGetBackfillJobByID = `
+	SELECT bj.id, bj.platform_id, bj.status, bj.target_reviews, bj.target_since, bj.page_delay_seconds,
+		bj.reviews_fetched, bj.pages_fetched, bj.error, bj.started_at, bj.finished_at, bj.created_at, bj.updated_at
+	FROM backfill_jobs bj
+	WHERE bj.id = :id`
+
+	queryGetActiveBackfillJobByPlatformID = `
+	SELECT bj.id, bj.platform_id, bj.status, bj.target_reviews, bj.target_since, bj.page_delay_seconds,
+		bj.reviews_fetched, bj.pages_fetched, bj.error, bj.started_at, bj.finished_at, bj.created_at, bj.updated_at
+	FROM backfill_jobs bj
+	WHERE bj.platform_id = :platform_id AND bj.status IN ('pending', 'running')`
+
+	queryGetActiveBackfillJobs = `
+	SELECT bj.id, bj.platform_id, bj.status, bj.target_reviews, bj.target_since, bj.page_delay_seconds,
+		bj.reviews_fetched, bj.pages_fetched, bj.error, bj.started_at, bj.finished_at, bj.created_at, bj.updated_at
+	FROM backfill_jobs bj
+	WHERE bj.status IN ('pending', 'running')
+	ORDER BY bj.created_at`
+
+	queryStartBackfillJob = `
+	UPDATE backfill_jobs
+	SET status = 'running',
+		started_at = COALESCE(started_at, NOW()),
+		updated_at = NOW()
+	WHERE id = :id`
+
+	queryAddBackfillJobProgress = `
+	UPDATE backfill_jobs
+	SET reviews_fetched = reviews_fetched + :reviews,
+		pages_fetched = pages_fetched + 1,
+		updated_at = NOW()
+	WHERE id = :id
+	RETURNING reviews_fetched`
+
+	queryFinishBackfillJob = `
+	UPDATE backfill_jobs
+	SET status = :status,
+		error = :error,
+		finished_at = NOW(),
+		updated_at = NOW()
+	WHERE id = :id`
+)
+
+// BackfillJob is a one-off historical scrape of a platform. It runs separately
+// from the daily incremental sync and stops at TargetReviews or TargetSince,
+// whichever is reached first.
+type BackfillJob struct {
+	ID               uuid.UUID                 `json:"id" db:"id"`
+	PlatformID       uuid.UUID                 `json:"platform_id" db:"platform_id"`
+	Status           consts.BackfillStatusType `json:"status" db:"status"`
+	TargetReviews    *int                      `json:"target_reviews" db:"target_reviews"`
+	TargetSince      *time.Time                `json:"target_since" db:"target_since"`
+	PageDelaySeconds int                       `json:"page_delay_seconds" db:"page_delay_seconds"`
+	ReviewsFetched   int                       `json:"reviews_fetched" db:"reviews_fetched"`
+	PagesFetched     int                       `json:"pages_fetched" db:"pages_fetched"`
+	Error            *string                   `json:"error" db:"error"`
+	StartedAt        *time.Time                `json:"started_at" db:"started_at"`
+	FinishedAt       *time.Time                `json:"finished_at" db:"finished_at"`
+	CreatedAt        time.Time                 `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time                 `json:"updated_at" db:"updated_at"`
+}
+
+func CreateBackfillJob(ctx context.Context, job *BackfillJob) error {
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	job.Status = consts.BackfillStatusPending
+
+	_, err := db.NamedExecContext(ctx, queryInsertBackfillJob, job)
+	if err != nil {
+		log.Error("Error while creating backfill job", err)
+		return err
+	}
+
+	return nil
+}
+
+func GetBackfillJobByID(ctx context.Context, jobID uuid.UUID) (*BackfillJob, error) {
+	var job BackfillJob
+
+	err := db.NamedGetContext(ctx, &job, queryGetBackfillJobByID, map[string]interface{}{
+		"id": jobID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Info("No backfill job found for id: ", jobID)
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching backfill job by id", err)
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func GetActiveBackfillJobByPlatformID(ctx context.Context, platformID uuid.UUID) (*BackfillJob, error) {
+	var job BackfillJob
+
+	err := db.NamedGetContext(ctx, &job, queryGetActiveBackfillJobByPlatformID, map[string]interface{}{
+		"platform_id": platformID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching active backfill job by platform id", err)
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// GetActiveBackfillJobs returns jobs that are pending or were interrupted while running
+func GetActiveBackfillJobs(ctx context.Context) ([]*BackfillJob, error) {
+	var jobs []*BackfillJob
+
+	err := db.NamedSelectContext(ctx, &jobs, queryGetActiveBackfillJobs, map[string]interface{}{})
+	if err != nil {
+		log.Error("Error while fetching active backfill jobs", err)
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func StartBackfillJob(ctx context.Context, jobID uuid.UUID) error {
+	_, err := db.NamedExecContext(ctx, queryStartBackfillJob, map[string]interface{}{
+		"id": jobID,
+	})
+	if err != nil {
+		log.Error("Error while starting backfill job", err)
+		return err
+	}
+
+	return nil
+}
+
+// AddBackfillJobProgress records a fetched page and returns the job's new review total
+func AddBackfillJobProgress(ctx context.Context, jobID uuid.UUID, reviews int) (int, error) {
+	var reviewsFetched int
+
+	err := db.NamedExecContextReturnID(ctx, queryAddBackfillJobProgress, map[string]interface{}{
+		"id":      jobID,
+		"reviews": reviews,
+	}, &reviewsFetched)
+	if err != nil {
+		log.Error("Error while updating backfill job progress", err)
+		return 0, err
+	}
+
+	return reviewsFetched, nil
+}
+
+func FinishBackfillJob(ctx context.Context, jobID uuid.UUID, status consts.BackfillStatusType, jobErr error) error {
+	var errMessage *string
+	if jobErr != nil {
+		message := jobErr.Error()
+		errMessage = &message
+	}
+
+	_, err := db.NamedExecContext(ctx, queryFinishBackfillJob, map[string]interface{}{
+		"id":     jobID,
+		"status": status,
+		"error":  errMessage,
+	})
+	if err != nil {
+		log.Error("Error while finishing backfill job", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	queryUpsertBrandToneSettings = `
+	INSERT INTO brand_tone_settings(product_id, tone, guidelines, signature, created_at, updated_at)
+	VALUES(:product_id, :tone, :guidelines, :signature, NOW(), NOW())
+	ON CONFLICT (product_id) DO UPDATE
+	SET tone = :tone,
+		guidelines = :guidelines,
+		signature = :signature,
+		updated_at = NOW()`
+
+	queryGetBrandToneSettingsByProductID = `
+	SELECT bt.product_id, bt.tone, bt.guidelines, bt.signature, bt.created_at, bt.updated_at
+	FROM brand_tone_settings bt
+	WHERE bt.product_id = :product_id`
+)
+
+// BrandToneSettings describes how replies to a product's reviews should sound
+type BrandToneSettings struct {
+	ProductID  uuid.UUID `json:"product_id" db:"product_id"`
+	Tone       string    `json:"tone" db:"tone"`
+	Guidelines string    `json:"guidelines" db:"guidelines"`
+	Signature  string    `json:"signature" db:"signature"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func UpsertBrandToneSettings(ctx context.Context, settings *BrandToneSettings) error {
+	_, err := db.NamedExecContext(ctx, queryUpsertBrandToneSettings, settings)
+	if err != nil {
+		log.Error("Error while upserting brand tone settings", err)
+		return err
+	}
+
+	return nil
+}
+
+func GetBrandToneSettingsByProductID(ctx context.Context, productID uuid.UUID) (*BrandToneSettings, error) {
+	var settings BrandToneSettings
+
+	err := db.NamedGetContext(ctx, &settings, queryGetBrandToneSettingsByProductID, map[string]interface{}{
+		"product_id": productID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching brand tone settings", err)
+		return nil, err
+	}
+
+	return &settings, nil
+}
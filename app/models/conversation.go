@@ -0,0 +1,151 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+const (
+	queryInsertConversation = `
+	INSERT INTO conversations(id, product_id, user_id, title, created_at, updated_at)
+	VALUES(:id, :product_id, :user_id, :title, NOW(), NOW())
+	RETURNING created_at`
+
+	queryGetConversationByIDAndUserID = `
+	SELECT c.id, c.product_id, c.user_id, c.title, c.created_at, c.updated_at
+	FROM conversations c
+	WHERE c.id = :id AND c.product_id = :product_id AND c.user_id = :user_id`
+
+	queryGetConversationsByProductIDAndUserID = `
+	SELECT c.id, c.product_id, c.user_id, c.title, c.created_at, c.updated_at
+	FROM conversations c
+	WHERE c.product_id = :product_id AND c.user_id = :user_id
+	ORDER BY c.updated_at DESC`
+
+	// Inserts the message and marks the conversation as updated
+	queryInsertConversationMessage = `
+	WITH touched AS (
+		UPDATE conversations
+		SET updated_at = NOW()
+		WHERE id = :conversation_id
+	)
+	INSERT INTO conversation_messages(id, conversation_id, role, content, review_ids, prompt_version, created_at)
+	VALUES(:id, :conversation_id, :role, :content, CAST(:review_ids AS UUID[]), :prompt_version, NOW())
+	RETURNING created_at`
+
+	queryGetConversationMessages = `
+	SELECT m.id, m.conversation_id, m.role, m.content, m.review_ids, m.prompt_version, m.created_at
+	FROM conversation_messages m
+	WHERE m.conversation_id = :conversation_id
+	ORDER BY m.created_at`
+)
+
+// Conversation is a user's thread of questions about a product's reviews
+type Conversation struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	ProductID uuid.UUID `json:"product_id" db:"product_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Title     string    `json:"title" db:"title"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ConversationMessage is a question or an answer. Answers carry the IDs of
+// the reviews they cite and the prompt that produced them.
+type ConversationMessage struct {
+	ID             uuid.UUID                   `json:"id" db:"id"`
+	ConversationID uuid.UUID                   `json:"conversation_id" db:"conversation_id"`
+	Role           consts.ConversationRoleType `json:"role" db:"role"`
+	Content        string                      `json:"content" db:"content"`
+	ReviewIDs      pq.StringArray              `json:"review_ids" db:"review_ids"`
+	PromptVersion  *string                     `json:"prompt_version" db:"prompt_version"`
+	CreatedAt      time.Time                   `json:"created_at" db:"created_at"`
+}
+
+func CreateConversation(ctx context.Context, conversation *Conversation) error {
+	if conversation.ID == uuid.Nil {
+		conversation.ID = uuid.New()
+	}
+
+	err := db.NamedExecContextReturnID(ctx, queryInsertConversation, conversation, &conversation.CreatedAt)
+	if err != nil {
+		log.Error("Error while creating conversation", err)
+		return err
+	}
+	conversation.UpdatedAt = conversation.CreatedAt
+
+	return nil
+}
+
+func GetConversationByIDAndUserID(ctx context.Context, conversationID, productID, userID uuid.UUID) (*Conversation, error) {
+	var conversation Conversation
+
+	err := db.NamedGetContext(ctx, &conversation, queryGetConversationByIDAndUserID, map[string]interface{}{
+		"id":         conversationID,
+		"product_id": productID,
+		"user_id":    userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching conversation by id and user id", err)
+		return nil, err
+	}
+
+	return &conversation, nil
+}
+
+// GetConversationsByProductIDAndUserID returns the user's conversations about
+// the product, most recently active first
+func GetConversationsByProductIDAndUserID(ctx context.Context, productID, userID uuid.UUID) ([]*Conversation, error) {
+	conversations := make([]*Conversation, 0)
+
+	err := db.NamedSelectContext(ctx, &conversations, queryGetConversationsByProductIDAndUserID, map[string]interface{}{
+		"product_id": productID,
+		"user_id":    userID,
+	})
+	if err != nil {
+		log.Error("Error while fetching conversations by product id and user id", err)
+		return nil, err
+	}
+
+	return conversations, nil
+}
+
+func CreateConversationMessage(ctx context.Context, message *ConversationMessage) error {
+	if message.ID == uuid.Nil {
+		message.ID = uuid.New()
+	}
+	if message.ReviewIDs == nil {
+		message.ReviewIDs = pq.StringArray{}
+	}
+
+	err := db.NamedExecContextReturnID(ctx, queryInsertConversationMessage, message, &message.CreatedAt)
+	if err != nil {
+		log.Error("Error while creating conversation message", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetConversationMessages returns the conversation's messages, oldest first
+func GetConversationMessages(ctx context.Context, conversationID uuid.UUID) ([]*ConversationMessage, error) {
+	messages := make([]*ConversationMessage, 0)
+
+	err := db.NamedSelectContext(ctx, &messages, queryGetConversationMessages, map[string]interface{}{
+		"conversation_id": conversationID,
+	})
+	if err != nil {
+		log.Error("Error while fetching conversation messages", err)
+		return nil, err
+	}
+
+	return messages, nil
+}
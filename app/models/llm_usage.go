@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	queryInsertLLMUsage = `
+	INSERT INTO llm_usage(id, provider, model, operation, product_id, user_id, prompt_tokens, completion_tokens, latency_ms, cost_usd, succeeded, created_at)
+	VALUES(:id, :provider, :model, :operation, :product_id, :user_id, :prompt_tokens, :completion_tokens, :latency_ms, :cost_usd, :succeeded, NOW())`
+
+	// Either filter may be NULL to match every row
+	queryGetLLMUsageSummary = `
+	SELECT
+		date_trunc(:period, u.created_at) as period_start,
+		u.provider,
+		u.model,
+		COUNT(*) as calls,
+		COUNT(*) FILTER (WHERE NOT u.succeeded) as failed_calls,
+		COALESCE(SUM(u.prompt_tokens), 0) as prompt_tokens,
+		COALESCE(SUM(u.completion_tokens), 0) as completion_tokens,
+		COALESCE(SUM(u.cost_usd), 0) as cost_usd,
+		ROUND(AVG(u.latency_ms)) as average_latency_ms
+	FROM llm_usage u
+	WHERE u.created_at >= :since
+	AND (CAST(:product_id AS UUID) IS NULL OR u.product_id = :product_id)
+	AND (CAST(:user_id AS UUID) IS NULL OR u.user_id = :user_id)
+	GROUP BY period_start, u.provider, u.model
+	ORDER BY period_start DESC, u.provider, u.model`
+)
+
+type LLMUsage struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	Provider         string     `json:"provider" db:"provider"`
+	Model            string     `json:"model" db:"model"`
+	Operation        string     `json:"operation" db:"operation"`
+	ProductID        *uuid.UUID `json:"product_id" db:"product_id"`
+	UserID           *uuid.UUID `json:"user_id" db:"user_id"`
+	PromptTokens     int        `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens" db:"completion_tokens"`
+	LatencyMs        int64      `json:"latency_ms" db:"latency_ms"`
+	CostUSD          float64    `json:"cost_usd" db:"cost_usd"`
+	Succeeded        bool       `json:"succeeded" db:"succeeded"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+type LLMUsageSummary struct {
+	PeriodStart      time.Time `json:"period_start" db:"period_start"`
+	Provider         string    `json:"provider" db:"provider"`
+	Model            string    `json:"model" db:"model"`
+	Calls            int       `json:"calls" db:"calls"`
+	FailedCalls      int       `json:"failed_calls" db:"failed_calls"`
+	PromptTokens     int64     `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens" db:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd" db:"cost_usd"`
+	AverageLatencyMs float64   `json:"average_latency_ms" db:"average_latency_ms"`
+}
+
+func CreateLLMUsage(ctx context.Context, usage *LLMUsage) error {
+	if usage.ID == uuid.Nil {
+		usage.ID = uuid.New()
+	}
+
+	_, err := db.NamedExecContext(ctx, queryInsertLLMUsage, usage)
+	if err != nil {
+		log.Error("Error while recording llm usage", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetLLMUsageSummary totals LLM usage since the given time per period
+// ("day", "week" or "month"), provider and model. productID and userID
+// narrow the summary when set.
+func GetLLMUsageSummary(ctx context.Context, productID, userID *uuid.UUID, period string, since time.Time) ([]*LLMUsageSummary, error) {
+	summary := make([]*LLMUsageSummary, 0)
+
+	err := db.NamedSelectContext(ctx, &summary, queryGetLLMUsageSummary, map[string]interface{}{
+		"product_id": productID,
+		"user_id":    userID,
+		"period":     period,
+		"since":      since,
+	})
+	if err != nil {
+		log.Error("Error while fetching llm usage summary", err)
+		return nil, err
+	}
+
+	return summary, nil
+}
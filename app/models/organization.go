@@ -0,0 +1,253 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+const (
+	// Creates the organization and makes the creator its owner in one statement
+	queryInsertOrganization = `
+	WITH organization AS (
+		INSERT INTO organizations(id, name, created_at, updated_at)
+		VALUES(:id, :name, NOW(), NOW())
+		RETURNING id, name, created_at, updated_at
+	), owner AS (
+		INSERT INTO organization_members(organization_id, user_id, role, created_at, updated_at)
+		SELECT o.id, :user_id, :role, NOW(), NOW()
+		FROM organization o
+	)
+	SELECT o.id, o.name, :role AS role, o.created_at, o.updated_at
+	FROM organization o`
+
+	queryGetOrganizationsByUserID = `
+	SELECT o.id, o.name, om.role, o.created_at, o.updated_at
+	FROM organizations o
+	INNER JOIN organization_members om ON om.organization_id = o.id
+	WHERE om.user_id = :user_id
+	ORDER BY o.created_at`
+
+	queryGetOrganizationByIDAndUserID = `
+	SELECT o.id, o.name, om.role, o.created_at, o.updated_at
+	FROM organizations o
+	INNER JOIN organization_members om ON om.organization_id = o.id
+	WHERE o.id = :id AND om.user_id = :user_id`
+
+	// The oldest organization the user owns, used when no organization is given
+	queryGetDefaultOrganizationByUserID = `
+	SELECT o.id, o.name, om.role, o.created_at, o.updated_at
+	FROM organizations o
+	INNER JOIN organization_members om ON om.organization_id = o.id
+	WHERE om.user_id = :user_id AND om.role = 'owner'
+	ORDER BY o.created_at
+	LIMIT 1`
+
+	queryGetOrganizationMembers = `
+	SELECT om.organization_id, om.user_id, u.name, u.email, om.role, om.created_at, om.updated_at
+	FROM organization_members om
+	INNER JOIN users u ON u.id = om.user_id
+	WHERE om.organization_id = :organization_id AND u.is_deleted = FALSE
+	ORDER BY om.created_at`
+
+	queryGetOrganizationMember = `
+	SELECT om.organization_id, om.user_id, u.name, u.email, om.role, om.created_at, om.updated_at
+	FROM organization_members om
+	INNER JOIN users u ON u.id = om.user_id
+	WHERE om.organization_id = :organization_id AND om.user_id = :user_id`
+
+	queryInsertOrganizationMember = `
+	INSERT INTO organization_members(organization_id, user_id, role, created_at, updated_at)
+	VALUES(:organization_id, :user_id, :role, NOW(), NOW())`
+
+	queryUpdateOrganizationMemberRole = `
+	UPDATE organization_members
+	SET role = :role, updated_at = NOW()
+	WHERE organization_id = :organization_id AND user_id = :user_id`
+
+	queryDeleteOrganizationMember = `
+	DELETE FROM organization_members
+	WHERE organization_id = :organization_id AND user_id = :user_id`
+
+	queryCountOrganizationOwners = `
+	SELECT COUNT(*)
+	FROM organization_members om
+	WHERE om.organization_id = :organization_id AND om.role = 'owner'`
+)
+
+// Organization is returned with Role set to the requesting user's role
+type Organization struct {
+	ID        uuid.UUID                   `json:"id" db:"id"`
+	Name      string                      `json:"name" db:"name"`
+	Role      consts.OrganizationRoleType `json:"role" db:"role"`
+	CreatedAt time.Time                   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time                   `json:"updated_at" db:"updated_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID                   `json:"organization_id" db:"organization_id"`
+	UserID         uuid.UUID                   `json:"user_id" db:"user_id"`
+	Name           string                      `json:"name" db:"name"`
+	Email          string                      `json:"email" db:"email"`
+	Role           consts.OrganizationRoleType `json:"role" db:"role"`
+	CreatedAt      time.Time                   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time                   `json:"updated_at" db:"updated_at"`
+}
+
+// CreateOrganization creates an organization owned by userID
+func CreateOrganization(ctx context.Context, name string, userID uuid.UUID) (*Organization, error) {
+	var organization Organization
+
+	err := db.NamedGetContext(ctx, &organization, queryInsertOrganization, map[string]interface{}{
+		"id":      uuid.New(),
+		"name":    name,
+		"user_id": userID,
+		"role":    consts.OrganizationRoleOwner,
+	})
+	if err != nil {
+		log.Error("Error while creating organization", err)
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+func GetOrganizationsByUserID(ctx context.Context, userID uuid.UUID) ([]*Organization, error) {
+	organizations := make([]*Organization, 0)
+
+	err := db.NamedSelectContext(ctx, &organizations, queryGetOrganizationsByUserID, map[string]interface{}{
+		"user_id": userID,
+	})
+	if err != nil {
+		log.Error("Error while fetching organizations by user id", err)
+		return nil, err
+	}
+
+	return organizations, nil
+}
+
+// GetOrganizationByIDAndUserID returns sql.ErrNoRows if the user is not a member
+func GetOrganizationByIDAndUserID(ctx context.Context, organizationID, userID uuid.UUID) (*Organization, error) {
+	var organization Organization
+
+	err := db.NamedGetContext(ctx, &organization, queryGetOrganizationByIDAndUserID, map[string]interface{}{
+		"id":      organizationID,
+		"user_id": userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching organization by id and user id", err)
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+func GetDefaultOrganizationByUserID(ctx context.Context, userID uuid.UUID) (*Organization, error) {
+	var organization Organization
+
+	err := db.NamedGetContext(ctx, &organization, queryGetDefaultOrganizationByUserID, map[string]interface{}{
+		"user_id": userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching default organization", err)
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+func GetOrganizationMembers(ctx context.Context, organizationID uuid.UUID) ([]*OrganizationMember, error) {
+	members := make([]*OrganizationMember, 0)
+
+	err := db.NamedSelectContext(ctx, &members, queryGetOrganizationMembers, map[string]interface{}{
+		"organization_id": organizationID,
+	})
+	if err != nil {
+		log.Error("Error while fetching organization members", err)
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func GetOrganizationMember(ctx context.Context, organizationID, userID uuid.UUID) (*OrganizationMember, error) {
+	var member OrganizationMember
+
+	err := db.NamedGetContext(ctx, &member, queryGetOrganizationMember, map[string]interface{}{
+		"organization_id": organizationID,
+		"user_id":         userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching organization member", err)
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func AddOrganizationMember(ctx context.Context, organizationID, userID uuid.UUID, role consts.OrganizationRoleType) error {
+	_, err := db.NamedExecContext(ctx, queryInsertOrganizationMember, map[string]interface{}{
+		"organization_id": organizationID,
+		"user_id":         userID,
+		"role":            role,
+	})
+	if err != nil {
+		log.Error("Error while adding organization member", err)
+		return err
+	}
+
+	return nil
+}
+
+func UpdateOrganizationMemberRole(ctx context.Context, organizationID, userID uuid.UUID, role consts.OrganizationRoleType) error {
+	_, err := db.NamedExecContext(ctx, queryUpdateOrganizationMemberRole, map[string]interface{}{
+		"organization_id": organizationID,
+		"user_id":         userID,
+		"role":            role,
+	})
+	if err != nil {
+		log.Error("Error while updating organization member role", err)
+		return err
+	}
+
+	return nil
+}
+
+func RemoveOrganizationMember(ctx context.Context, organizationID, userID uuid.UUID) error {
+	_, err := db.NamedExecContext(ctx, queryDeleteOrganizationMember, map[string]interface{}{
+		"organization_id": organizationID,
+		"user_id":         userID,
+	})
+	if err != nil {
+		log.Error("Error while removing organization member", err)
+		return err
+	}
+
+	return nil
+}
+
+func CountOrganizationOwners(ctx context.Context, organizationID uuid.UUID) (int, error) {
+	var count int
+
+	err := db.NamedGetContext(ctx, &count, queryCountOrganizationOwners, map[string]interface{}{
+		"organization_id": organizationID,
+	})
+	if err != nil {
+		log.Error("Error while counting organization owners", err)
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -30,8 +30,8 @@ const (
 	SELECT p.id, p.name, p.url, p.product_id, p.created_at, p.updated_at
 	FROM platforms p
 	JOIN products ON products.id = p.product_id
-	JOIN users u ON products.user_id = u.id
-	WHERE p.product_id = :product_id AND u.id = :user_id`
+	JOIN organization_members om ON om.organization_id = products.organization_id
+	WHERE p.product_id = :product_id AND om.user_id = :user_id`
 
 	queryUpdatePlatformByID = `
 	UPDATE platforms SET 
@@ -6,51 +6,60 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
 )
 
 const (
 	queryInsertProduct = `
-	INSERT INTO products(id, user_id, name, description, created_at, updated_at)
-	VALUES(:id, :user_id, :name, :description, NOW(), NOW())`
+	INSERT INTO products(id, user_id, organization_id, name, description, created_at, updated_at)
+	VALUES(:id, :user_id, :organization_id, :name, :description, NOW(), NOW())`
 
 	queryGetProductByID = `
-	SELECT p.id, p.user_id, p.name, p.description, p.created_at, p.updated_at
+	SELECT p.id, p.user_id, p.organization_id, p.name, p.description, p.created_at, p.updated_at
 	FROM products p
 	WHERE p.id = :id`
 
 	queryGetProductByIDAndUserID = `
-	SELECT p.id, p.user_id, p.name, p.description, p.created_at, p.updated_at
+	SELECT p.id, p.user_id, p.organization_id, om.role, p.name, p.description, p.created_at, p.updated_at
 	FROM products p
-	WHERE p.id = :id AND p.user_id = :user_id`
+	INNER JOIN organization_members om ON om.organization_id = p.organization_id
+	WHERE p.id = :id AND om.user_id = :user_id AND p.is_deleted = FALSE`
 
-	queryGetProductsByUserID = `
-	SELECT p.id, p.name, p.description, p.created_at, p.updated_at
-	FROM products p
-	WHERE p.is_deleted = FALSE AND p.user_id = :user_id`
+	queryGetProductByReviewIDAndUserID = `
+	SELECT p.id, p.user_id, p.organization_id, om.role, p.name, p.description, p.created_at, p.updated_at
+	FROM reviews r
+	INNER JOIN platforms plt ON plt.id = r.platform_id
+	INNER JOIN products p ON p.id = plt.product_id
+	INNER JOIN organization_members om ON om.organization_id = p.organization_id
+	WHERE r.id = :review_id AND om.user_id = :user_id AND p.is_deleted = FALSE`
 
 	queryUpdateProductByID = `
 	UPDATE products
 	SET name = :name, description = :description, updated_at = NOW()
 	WHERE id = :product_id`
 
-	queryGetProductByNameAndUserID = `
-	SELECT p.id, p.user_id, p.name, p.description, p.created_at, p.updated_at
+	queryGetProductByNameAndOrganizationID = `
+	SELECT p.id, p.user_id, p.organization_id, p.name, p.description, p.created_at, p.updated_at
 	FROM products p
-	WHERE p.is_deleted = FALSE AND p.name = :name AND p.user_id = :user_id`
+	WHERE p.is_deleted = FALSE AND p.name = :name AND p.organization_id = :organization_id`
 
 	queryDeleteProduct = `
 	UPDATE products
 	SET is_deleted = TRUE
-	WHERE id = :id AND user_id = :user_id`
+	WHERE id = :id
+	AND organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = :user_id)`
 
 	queryGetAllProducts = `
-	SELECT p.id, p.user_id, p.name, p.description, p.created_at, p.updated_at
-	FROM products p`
+	SELECT p.id, p.user_id, p.organization_id, p.name, p.description, p.created_at, p.updated_at
+	FROM products p
+	WHERE p.is_deleted = FALSE`
 
 	queryGetProductsWithReviewStats = `
 	SELECT 
 		p.id, 
 		p.user_id, 
+		p.organization_id,
+		om.role,
 		p.name, 
 		p.description, 
 		p.created_at, 
@@ -58,20 +67,25 @@ const (
 		COUNT(DISTINCT r.id) as review_count,
 		ROUND(COALESCE(AVG(r.rating_value), 0), 2) as average_rating
 	FROM products p
+	INNER JOIN organization_members om ON om.organization_id = p.organization_id AND om.user_id = :user_id
 	LEFT JOIN platforms plt ON plt.product_id = p.id
 	LEFT JOIN reviews r ON r.platform_id = plt.id
-	WHERE p.is_deleted = FALSE AND p.user_id = :user_id
-	GROUP BY p.id, p.user_id, p.name, p.description, p.created_at, p.updated_at`
+	WHERE p.is_deleted = FALSE
+	GROUP BY p.id, p.user_id, p.organization_id, om.role, p.name, p.description, p.created_at, p.updated_at`
 )
 
+// Product.UserID is the product's creator. Role is the requesting user's role
+// in the owning organization and is only set by the user scoped queries.
 type Product struct {
-	ID          uuid.UUID   `json:"id" db:"id"`
-	UserID      uuid.UUID   `json:"user_id" db:"user_id"`
-	Name        string      `json:"name" db:"name"`
-	Description string      `json:"description" db:"description"`
-	Platforms   []*Platform `json:"platforms" db:"platforms"`
-	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+	ID             uuid.UUID                   `json:"id" db:"id"`
+	UserID         uuid.UUID                   `json:"user_id" db:"user_id"`
+	OrganizationID uuid.UUID                   `json:"organization_id" db:"organization_id"`
+	Role           consts.OrganizationRoleType `json:"role,omitempty" db:"role"`
+	Name           string                      `json:"name" db:"name"`
+	Description    string                      `json:"description" db:"description"`
+	Platforms      []*Platform                 `json:"platforms" db:"platforms"`
+	CreatedAt      time.Time                   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time                   `json:"updated_at" db:"updated_at"`
 }
 
 type ProductWithReviewStats struct {
@@ -128,6 +142,27 @@ func GetProductByIDAndUserID(ctx context.Context, productID, userID uuid.UUID) (
 	return &product, nil
 }
 
+// GetProductByReviewIDAndUserID returns the product a review belongs to, with
+// the user's role, if the user is a member of its organization
+func GetProductByReviewIDAndUserID(ctx context.Context, reviewID, userID uuid.UUID) (*Product, error) {
+	var product Product
+
+	err := db.NamedGetContext(ctx, &product, queryGetProductByReviewIDAndUserID, map[string]interface{}{
+		"review_id": reviewID,
+		"user_id":   userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Info("No product found for review id: ", reviewID)
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching product by review id", err)
+		return nil, err
+	}
+
+	return &product, nil
+}
+
 func GetProductsByUserID(ctx context.Context, userID uuid.UUID) ([]*ProductWithReviewStats, error) {
 	var product []*ProductWithReviewStats
 
@@ -146,19 +181,19 @@ func GetProductsByUserID(ctx context.Context, userID uuid.UUID) ([]*ProductWithR
 	return product, nil
 }
 
-func GetProductByNameAndUserID(ctx context.Context, name string, userID uuid.UUID) (*Product, error) {
+func GetProductByNameAndOrganizationID(ctx context.Context, name string, organizationID uuid.UUID) (*Product, error) {
 	var product Product
 
-	err := db.NamedGetContext(ctx, &product, queryGetProductByNameAndUserID, map[string]interface{}{
-		"name":    name,
-		"user_id": userID,
+	err := db.NamedGetContext(ctx, &product, queryGetProductByNameAndOrganizationID, map[string]interface{}{
+		"name":            name,
+		"organization_id": organizationID,
 	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Info("No product found for name: ", name)
 			return nil, sql.ErrNoRows
 		}
-		log.Error("Error while fetching product by name and user ID", err)
+		log.Error("Error while fetching product by name and organization ID", err)
 		return nil, err
 	}
 
@@ -2,6 +2,9 @@ package models
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,18 +14,32 @@ import (
 
 const (
 	queryUpsertProductStats = `
-	INSERT INTO product_stats (product_id, platform, time_period, key_highlights, pain_points, overall_sentiment, sentiment_count)
-	VALUES (:product_id, :platform, :time_period, CAST(:key_highlights AS text[]), CAST(:pain_points AS text[]), :overall_sentiment, :sentiment_count)
+	INSERT INTO product_stats (product_id, platform, time_period, key_highlights, pain_points, overall_sentiment, sentiment_count, summary_prompt_version, sentiment_prompt_version)
+	VALUES (:product_id, :platform, :time_period, CAST(:key_highlights AS text[]), CAST(:pain_points AS text[]), :overall_sentiment, CAST(:sentiment_count AS JSONB), :summary_prompt_version, :sentiment_prompt_version)
 	ON CONFLICT (product_id, platform, time_period) DO UPDATE
 	SET key_highlights = CAST(:key_highlights AS text[]),
 		pain_points = CAST(:pain_points AS text[]),
 		overall_sentiment = :overall_sentiment,
-		sentiment_count = :sentiment_count,
+		sentiment_count = CAST(:sentiment_count AS JSONB),
+		summary_prompt_version = :summary_prompt_version,
+		sentiment_prompt_version = :sentiment_prompt_version,
+		updated_at = CURRENT_TIMESTAMP
+	`
+
+	// Stores a summary without touching the row's sentiment counts
+	queryUpsertProductStatsSummary = `
+	INSERT INTO product_stats (product_id, platform, time_period, key_highlights, pain_points, overall_sentiment, sentiment_count, summary_prompt_version)
+	VALUES (:product_id, :platform, :time_period, CAST(:key_highlights AS text[]), CAST(:pain_points AS text[]), :overall_sentiment, CAST('[]' AS JSONB), :summary_prompt_version)
+	ON CONFLICT (product_id, platform, time_period) DO UPDATE
+	SET key_highlights = CAST(:key_highlights AS text[]),
+		pain_points = CAST(:pain_points AS text[]),
+		overall_sentiment = :overall_sentiment,
+		summary_prompt_version = :summary_prompt_version,
 		updated_at = CURRENT_TIMESTAMP
 	`
 
 	queryGetProductStats = `
-	SELECT product_id, platform, time_period, key_highlights, pain_points, overall_sentiment, sentiment_count
+	SELECT product_id, platform, time_period, key_highlights, pain_points, overall_sentiment, sentiment_count, summary_prompt_version, sentiment_prompt_version
 	FROM product_stats
 	WHERE product_id = :product_id
 	AND platform = :platform
@@ -37,15 +54,59 @@ type ProductStats struct {
 	KeyHighlights    pq.StringArray        `json:"key_highlights" db:"key_highlights"`
 	PainPoints       pq.StringArray        `json:"pain_points" db:"pain_points"`
 	OverallSentiment string                `json:"overall_sentiment" db:"overall_sentiment"`
-	SentimentCount   pq.StringArray        `json:"sentiment_count" db:"sentiment_count"`
-	CreatedAt        time.Time             `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time             `json:"updated_at" db:"updated_at"`
+	SentimentCount   SentimentCounts       `json:"sentiment_count" db:"sentiment_count"`
+	// The prompts.Prompt IDs that produced the stats, e.g. "summary/v1"
+	SummaryPromptVersion   *string   `json:"summary_prompt_version" db:"summary_prompt_version"`
+	SentimentPromptVersion *string   `json:"sentiment_prompt_version" db:"sentiment_prompt_version"`
+	CreatedAt              time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SentimentCount is how many reviews are positive, negative or have no
+// opinion about a sentiment category
+type SentimentCount struct {
+	Category  string `json:"category"`
+	Positive  int    `json:"positive"`
+	Negative  int    `json:"negative"`
+	NoOpinion int    `json:"no_opinion"`
+}
+
+// SentimentCounts is stored as a JSONB array
+type SentimentCounts []SentimentCount
+
+func (s SentimentCounts) Value() (driver.Value, error) {
+	if s == nil {
+		s = SentimentCounts{}
+	}
+
+	countsJSON, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(countsJSON), nil
+}
+
+func (s *SentimentCounts) Scan(src interface{}) error {
+	var countsJSON []byte
+	switch src := src.(type) {
+	case nil:
+		*s = SentimentCounts{}
+		return nil
+	case []byte:
+		countsJSON = src
+	case string:
+		countsJSON = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into SentimentCounts", src)
+	}
+
+	return json.Unmarshal(countsJSON, s)
 }
 
 func CreateProductStats(ctx context.Context, productStats *ProductStats) error {
 	productStats.KeyHighlights = pq.StringArray(productStats.KeyHighlights)
 	productStats.PainPoints = pq.StringArray(productStats.PainPoints)
-	productStats.SentimentCount = pq.StringArray(productStats.SentimentCount)
 	_, err := db.NamedExecContext(ctx, queryUpsertProductStats, productStats)
 	if err != nil {
 		log.Error("Error while upserting product stats", err)
@@ -55,6 +116,18 @@ func CreateProductStats(ctx context.Context, productStats *ProductStats) error {
 	return nil
 }
 
+// UpsertProductStatsSummary stores the key highlights, pain points and
+// overall sentiment of the stats, keeping any sentiment counts already stored
+func UpsertProductStatsSummary(ctx context.Context, productStats *ProductStats) error {
+	_, err := db.NamedExecContext(ctx, queryUpsertProductStatsSummary, productStats)
+	if err != nil {
+		log.Error("Error while upserting product stats summary", err)
+		return err
+	}
+
+	return nil
+}
+
 func GetProductStats(ctx context.Context, productID uuid.UUID, platform consts.PlatformType, timePeriod consts.TimePeriodType) (*ProductStats, error) {
 	productStats := &ProductStats{}
 	err := db.NamedGetContext(ctx, productStats, queryGetProductStats, map[string]interface{}{
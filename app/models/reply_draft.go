@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+const (
+	queryInsertReplyDraft = `
+	INSERT INTO review_reply_drafts(id, review_id, created_by, body, status, model, created_at, updated_at)
+	VALUES(:id, :review_id, :created_by, :body, :status, :model, NOW(), NOW())
+	RETURNING created_at`
+
+	queryGetReplyDraftByID = `
+	SELECT d.id, d.review_id, d.created_by, d.body, d.status, d.model, d.approved_by, d.approved_at, d.posted_at, d.created_at, d.updated_at
+	FROM review_reply_drafts d
+	WHERE d.id = :id AND d.review_id = :review_id`
+
+	queryGetReplyDraftsByReviewID = `
+	SELECT d.id, d.review_id, d.created_by, d.body, d.status, d.model, d.approved_by, d.approved_at, d.posted_at, d.created_at, d.updated_at
+	FROM review_reply_drafts d
+	WHERE d.review_id = :review_id
+	ORDER BY d.created_at DESC`
+
+	queryUpdateReplyDraft = `
+	UPDATE review_reply_drafts
+	SET body = :body,
+		status = :status,
+		approved_by = :approved_by,
+		approved_at = :approved_at,
+		posted_at = :posted_at,
+		updated_at = NOW()
+	WHERE id = :id`
+)
+
+// ReplyDraft is an LLM-drafted reply to a review. Drafts are kept after they
+// are approved or posted so there is a record of who approved what.
+type ReplyDraft struct {
+	ID         uuid.UUID                   `json:"id" db:"id"`
+	ReviewID   uuid.UUID                   `json:"review_id" db:"review_id"`
+	CreatedBy  uuid.UUID                   `json:"created_by" db:"created_by"`
+	Body       string                      `json:"body" db:"body"`
+	Status     consts.ReplyDraftStatusType `json:"status" db:"status"`
+	Model      string                      `json:"model" db:"model"`
+	ApprovedBy *uuid.UUID                  `json:"approved_by" db:"approved_by"`
+	ApprovedAt *time.Time                  `json:"approved_at" db:"approved_at"`
+	PostedAt   *time.Time                  `json:"posted_at" db:"posted_at"`
+	CreatedAt  time.Time                   `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time                   `json:"updated_at" db:"updated_at"`
+}
+
+func CreateReplyDraft(ctx context.Context, draft *ReplyDraft) error {
+	if draft.ID == uuid.Nil {
+		draft.ID = uuid.New()
+	}
+
+	err := db.NamedExecContextReturnID(ctx, queryInsertReplyDraft, draft, &draft.CreatedAt)
+	if err != nil {
+		log.Error("Error while creating reply draft", err)
+		return err
+	}
+	draft.UpdatedAt = draft.CreatedAt
+
+	return nil
+}
+
+func GetReplyDraftByID(ctx context.Context, draftID, reviewID uuid.UUID) (*ReplyDraft, error) {
+	var draft ReplyDraft
+
+	err := db.NamedGetContext(ctx, &draft, queryGetReplyDraftByID, map[string]interface{}{
+		"id":        draftID,
+		"review_id": reviewID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching reply draft by id", err)
+		return nil, err
+	}
+
+	return &draft, nil
+}
+
+func GetReplyDraftsByReviewID(ctx context.Context, reviewID uuid.UUID) ([]*ReplyDraft, error) {
+	drafts := make([]*ReplyDraft, 0)
+
+	err := db.NamedSelectContext(ctx, &drafts, queryGetReplyDraftsByReviewID, map[string]interface{}{
+		"review_id": reviewID,
+	})
+	if err != nil {
+		log.Error("Error while fetching reply drafts by review id", err)
+		return nil, err
+	}
+
+	return drafts, nil
+}
+
+func UpdateReplyDraft(ctx context.Context, draft *ReplyDraft) error {
+	_, err := db.NamedExecContext(ctx, queryUpdateReplyDraft, draft)
+	if err != nil {
+		log.Error("Error while updating reply draft", err)
+		return err
+	}
+
+	return nil
+}
@@ -8,26 +8,46 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/review-aggregator/review-api/app/consts"
 	"github.com/review-aggregator/review-api/app/utils"
 )
 
 const (
 	queryInsertReview = `
-	INSERT INTO reviews(id, platform_id, url, author_name, date_published, headline, review_body, rating_value, language, created_at, updated_at)
-	VALUES(:id, :platform_id, :url, :author_name, :date_published, :headline, :review_body, :rating_value, :language, NOW(), NOW())
-	ON CONFLICT (url) DO NOTHING`
+	INSERT INTO reviews(id, platform_id, url, author_name, date_published, headline, review_body, rating_value, language, response_body, response_author, response_date, created_at, updated_at)
+	VALUES(:id, :platform_id, :url, :author_name, :date_published, :headline, :review_body, :rating_value, :language, :response_body, :response_author, :response_date, NOW(), NOW())
+	ON CONFLICT (url) DO UPDATE
+	SET response_body = COALESCE(EXCLUDED.response_body, reviews.response_body),
+		response_author = COALESCE(EXCLUDED.response_author, reviews.response_author),
+		response_date = COALESCE(EXCLUDED.response_date, reviews.response_date),
+		updated_at = NOW()
+	WHERE EXCLUDED.response_body IS NOT NULL AND reviews.response_body IS NULL`
 
 	queryGetReviewByID = `
-	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.created_at, r.updated_at
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
 	FROM reviews r
 	WHERE r.id = :id`
 
+	queryGetReviewByIDAndUserID = `
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
+	FROM reviews r
+	INNER JOIN platforms p ON p.id = r.platform_id
+	INNER JOIN products pr ON pr.id = p.product_id
+	WHERE r.id = :id AND pr.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = :user_id) AND pr.is_deleted = FALSE`
+
 	queryGetReviewsByPlatformID = `
-	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.created_at, r.updated_at
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
 	FROM reviews r
 	WHERE r.platform_id = :platform_id`
 
+	queryGetReviewsByProductID = `
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
+	FROM reviews r
+	INNER JOIN platforms p ON p.id = r.platform_id
+	WHERE p.product_id = :product_id
+	ORDER BY r.date_published`
+
 	queryGetLatestReviewDateByPlatformID = `
 	SELECT r.date_published
 	FROM reviews r
@@ -36,25 +56,25 @@ const (
 	LIMIT 1`
 
 	querySelectAllReviews = `
-	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.created_at, r.updated_at
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
 	FROM reviews r`
 
 	queryGetReviewsByProductIDAndUserID = `
-	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.created_at, r.updated_at
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
 	FROM reviews r
 	INNER JOIN platforms p ON p.id = r.platform_id
 	INNER JOIN products pr ON pr.id = p.product_id
-	WHERE pr.id = :product_id AND pr.user_id = :user_id`
+	WHERE pr.id = :product_id AND pr.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = :user_id)`
 
 	queryGetReviewsByProductIDAndUserIDAndTimePeriod = `
-	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.created_at, r.updated_at
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
 	FROM reviews r
 	INNER JOIN platforms p ON p.id = r.platform_id
 	INNER JOIN products pr ON pr.id = p.product_id
-	WHERE pr.id = :product_id AND pr.user_id = :user_id AND r.date_published BETWEEN :date_from AND :date_to`
+	WHERE pr.id = :product_id AND pr.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = :user_id) AND r.date_published BETWEEN :date_from AND :date_to`
 
 	queryGetReviewsByPlatformIDAndUserIDAndTimePeriod = `
-	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.created_at, r.updated_at
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
 	FROM reviews r
 	INNER JOIN platforms p ON p.id = r.platform_id
 	WHERE p.id = :platform_id AND r.date_published BETWEEN :date_from AND :date_to`
@@ -67,8 +87,54 @@ const (
 	INNER JOIN platforms p ON p.id = r.platform_id
 	INNER JOIN products pr ON pr.id = p.product_id
 	WHERE pr.id = :product_id AND r.date_published BETWEEN :date_from AND :date_to
+	AND (CAST(:suspicion_threshold AS DOUBLE PRECISION) = 0 OR COALESCE(r.suspicion_score, 0) < CAST(:suspicion_threshold AS DOUBLE PRECISION))
 	GROUP BY CAST(rating_value AS INTEGER)
 	ORDER BY rating`
+
+	queryGetResponseMetrics = `
+	SELECT
+		COUNT(*) as review_count,
+		COUNT(r.response_body) as responded_count,
+		COALESCE(percentile_cont(0.5) WITHIN GROUP (
+			ORDER BY EXTRACT(EPOCH FROM (r.response_date - r.date_published)) / 3600
+		) FILTER (WHERE r.response_date IS NOT NULL), 0) as median_response_hours
+	FROM reviews r
+	INNER JOIN platforms p ON p.id = r.platform_id
+	INNER JOIN products pr ON pr.id = p.product_id
+	WHERE pr.id = :product_id
+	AND (:platform IN ('', 'all') OR p.name = :platform)
+	AND r.date_published BETWEEN :date_from AND :date_to`
+
+	queryGetUnansweredNegativeReviews = `
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
+	FROM reviews r
+	INNER JOIN platforms p ON p.id = r.platform_id
+	INNER JOIN products pr ON pr.id = p.product_id
+	WHERE pr.id = :product_id AND pr.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = :user_id)
+	AND (:platform IN ('', 'all') OR p.name = :platform)
+	AND r.date_published BETWEEN :date_from AND :date_to
+	AND r.response_body IS NULL
+	AND r.rating_value <= :max_rating
+	ORDER BY r.date_published DESC`
+
+	// Ranks the product's reviews by how many words of :query they contain,
+	// newest first among equals. An empty :query matches every review.
+	querySearchReviews = `
+	WITH search AS (
+		SELECT to_tsquery('english', replace(CAST(plainto_tsquery('english', :query) AS TEXT), '&', '|')) AS query
+	)
+	SELECT r.id, r.platform_id, r.url, r.author_name, r.date_published, r.headline, r.review_body, r.rating_value, r.language, r.response_body, r.response_author, r.response_date, r.suspicion_score, r.suspicion_reasons, r.suspicion_llm_score, r.created_at, r.updated_at
+	FROM reviews r
+	INNER JOIN platforms p ON p.id = r.platform_id
+	INNER JOIN products pr ON pr.id = p.product_id
+	CROSS JOIN search s
+	WHERE pr.id = :product_id AND pr.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = :user_id) AND pr.is_deleted = FALSE
+	AND (:platform IN ('', 'all') OR p.name = :platform)
+	AND r.date_published >= CAST(:date_from AS DATE) AND r.date_published < CAST(:date_to AS DATE) + 1
+	AND r.rating_value BETWEEN :min_rating AND :max_rating
+	AND (numnode(s.query) = 0 OR to_tsvector('english', COALESCE(r.headline, '') || ' ' || COALESCE(r.review_body, '')) @@ s.query)
+	ORDER BY ts_rank(to_tsvector('english', COALESCE(r.headline, '') || ' ' || COALESCE(r.review_body, '')), s.query) DESC, r.date_published DESC
+	LIMIT :limit`
 )
 
 type Review struct {
@@ -81,8 +147,38 @@ type Review struct {
 	ReviewBody    string    `db:"review_body" json:"review_body"`
 	RatingValue   float64   `db:"rating_value" json:"rating_value"`
 	Language      string    `db:"language" json:"language"`
-	CreatedAt     time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+	// Owner's reply to the review, nil when nobody has answered yet
+	ResponseBody   *string `db:"response_body" json:"response_body"`
+	ResponseAuthor *string `db:"response_author" json:"response_author"`
+	ResponseDate   *string `db:"response_date" json:"response_date"`
+	// How likely the review is fake or spam from 0 to 1, nil until scored
+	SuspicionScore    *float64       `db:"suspicion_score" json:"suspicion_score"`
+	SuspicionReasons  pq.StringArray `db:"suspicion_reasons" json:"suspicion_reasons"`
+	SuspicionLLMScore *float64       `db:"suspicion_llm_score" json:"suspicion_llm_score"`
+	CreatedAt         time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// ReviewSearch filters the reviews SearchReviews returns. Dates are
+// "2006-01-02" and inclusive.
+type ReviewSearch struct {
+	ProductID uuid.UUID
+	UserID    uuid.UUID
+	Query     string
+	Platform  consts.PlatformType
+	DateFrom  string
+	DateTo    string
+	MinRating float64
+	MaxRating float64
+	Limit     int
+}
+
+// ResponseMetrics summarises how the owner answers reviews in a period
+type ResponseMetrics struct {
+	ReviewCount         int64   `db:"review_count" json:"review_count"`
+	RespondedCount      int64   `db:"responded_count" json:"responded_count"`
+	ResponseRate        float64 `db:"-" json:"response_rate"`
+	MedianResponseHours float64 `db:"median_response_hours" json:"median_response_hours"`
 }
 
 type ReviewRating struct {
@@ -146,6 +242,25 @@ func GetReviewByID(ctx context.Context, reviewID uuid.UUID) (*Review, error) {
 	return &review, nil
 }
 
+func GetReviewByIDAndUserID(ctx context.Context, reviewID, userID uuid.UUID) (*Review, error) {
+	var review Review
+
+	err := db.NamedGetContext(ctx, &review, queryGetReviewByIDAndUserID, map[string]interface{}{
+		"id":      reviewID,
+		"user_id": userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Info("No review found for id: ", reviewID)
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching review by id and user id", err)
+		return nil, err
+	}
+
+	return &review, nil
+}
+
 func GetReviewsByPlatformID(ctx context.Context, platformID uuid.UUID) ([]*Review, error) {
 	var reviews []*Review
 
@@ -164,6 +279,22 @@ func GetReviewsByPlatformID(ctx context.Context, platformID uuid.UUID) ([]*Revie
 	return reviews, nil
 }
 
+// GetReviewsByProductID returns the reviews of every platform of the
+// product, oldest first
+func GetReviewsByProductID(ctx context.Context, productID uuid.UUID) ([]*Review, error) {
+	reviews := make([]*Review, 0)
+
+	err := db.NamedSelectContext(ctx, &reviews, queryGetReviewsByProductID, map[string]interface{}{
+		"product_id": productID,
+	})
+	if err != nil {
+		log.Error("Error while fetching reviews by product id", err)
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
 func GetLatestReviewDateByPlatformID(ctx context.Context, platformID uuid.UUID) (string, error) {
 	var reviewDate string
 
@@ -235,16 +366,20 @@ func GetReviewsByProductIDAndUserIDAndTimePeriod(ctx context.Context, productID
 	return reviews, nil
 }
 
-func GetReviewRatings(ctx context.Context, productID uuid.UUID, platform consts.PlatformType, timePeriod consts.TimePeriodType) ([]*ReviewRating, error) {
+// GetReviewRatings counts the product's reviews per rating. Reviews with a
+// suspicion score of suspicionThreshold or higher are left out, a threshold
+// of 0 counts every review.
+func GetReviewRatings(ctx context.Context, productID uuid.UUID, platform consts.PlatformType, timePeriod consts.TimePeriodType, suspicionThreshold float64) ([]*ReviewRating, error) {
 	var reviewRatings []*ReviewRating
 
 	dateFrom, dateTo := getDateFromAndDateTo(timePeriod)
 
 	err := db.NamedSelectContext(ctx, &reviewRatings, queryGetReviewRatings, map[string]interface{}{
-		"product_id": productID,
-		"platform":   platform,
-		"date_from":  dateFrom,
-		"date_to":    dateTo,
+		"product_id":          productID,
+		"platform":            platform,
+		"date_from":           dateFrom,
+		"date_to":             dateTo,
+		"suspicion_threshold": suspicionThreshold,
 	})
 	if err != nil {
 		log.Error("Error while fetching review ratings", err)
@@ -303,3 +438,71 @@ func GetReviews(ctx context.Context) ([]*Review, error) {
 
 	return reviews, nil
 }
+
+func GetResponseMetrics(ctx context.Context, productID uuid.UUID, platform consts.PlatformType, timePeriod consts.TimePeriodType) (*ResponseMetrics, error) {
+	var metrics ResponseMetrics
+
+	dateFrom, dateTo := getDateFromAndDateTo(timePeriod)
+
+	err := db.NamedGetContext(ctx, &metrics, queryGetResponseMetrics, map[string]interface{}{
+		"product_id": productID,
+		"platform":   platform,
+		"date_from":  dateFrom,
+		"date_to":    dateTo,
+	})
+	if err != nil {
+		log.Error("Error while fetching response metrics", err)
+		return nil, err
+	}
+
+	if metrics.ReviewCount > 0 {
+		metrics.ResponseRate = float64(metrics.RespondedCount) / float64(metrics.ReviewCount)
+	}
+
+	return &metrics, nil
+}
+
+// GetUnansweredNegativeReviews returns reviews rated maxRating or lower that have no owner reply, newest first
+func GetUnansweredNegativeReviews(ctx context.Context, productID, userID uuid.UUID, platform consts.PlatformType, timePeriod consts.TimePeriodType, maxRating float64) ([]*Review, error) {
+	reviews := make([]*Review, 0)
+
+	dateFrom, dateTo := getDateFromAndDateTo(timePeriod)
+
+	err := db.NamedSelectContext(ctx, &reviews, queryGetUnansweredNegativeReviews, map[string]interface{}{
+		"product_id": productID,
+		"user_id":    userID,
+		"platform":   platform,
+		"date_from":  dateFrom,
+		"date_to":    dateTo,
+		"max_rating": maxRating,
+	})
+	if err != nil {
+		log.Error("Error while fetching unanswered negative reviews", err)
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// SearchReviews returns the reviews matching the search, best matches first
+func SearchReviews(ctx context.Context, search ReviewSearch) ([]*Review, error) {
+	reviews := make([]*Review, 0)
+
+	err := db.NamedSelectContext(ctx, &reviews, querySearchReviews, map[string]interface{}{
+		"product_id": search.ProductID,
+		"user_id":    search.UserID,
+		"query":      search.Query,
+		"platform":   search.Platform,
+		"date_from":  search.DateFrom,
+		"date_to":    search.DateTo,
+		"min_rating": search.MinRating,
+		"max_rating": search.MaxRating,
+		"limit":      search.Limit,
+	})
+	if err != nil {
+		log.Error("Error while searching reviews", err)
+		return nil, err
+	}
+
+	return reviews, nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+const (
+	// Replaces the product's anomalies from :date_from on in one statement.
+	// :anomalies is a JSON array of ReviewAnomaly.
+	queryReplaceReviewAnomalies = `
+	WITH deleted AS (
+		DELETE FROM review_anomalies
+		WHERE product_id = :product_id AND date >= CAST(:date_from AS DATE)
+	)
+	INSERT INTO review_anomalies(product_id, platform, date, metric, direction, value, baseline_mean, baseline_stddev, z_score, review_count, created_at)
+	SELECT :product_id, a.platform, a.date, a.metric, a.direction, a.value, a.baseline_mean, a.baseline_stddev, a.z_score, a.review_count, NOW()
+	FROM jsonb_to_recordset(CAST(:anomalies AS JSONB)) AS a(
+		platform TEXT, date DATE, metric TEXT, direction TEXT, value DOUBLE PRECISION,
+		baseline_mean DOUBLE PRECISION, baseline_stddev DOUBLE PRECISION, z_score DOUBLE PRECISION, review_count INTEGER
+	)`
+
+	queryGetReviewAnomalies = `
+	SELECT a.id, a.product_id, a.platform, a.date, a.metric, a.direction, a.value, a.baseline_mean, a.baseline_stddev, a.z_score, a.review_count, a.created_at
+	FROM review_anomalies a
+	WHERE a.product_id = :product_id
+	AND (:platform IN ('', 'all') OR a.platform = :platform)
+	AND a.date BETWEEN CAST(:date_from AS DATE) AND CAST(:date_to AS DATE)
+	ORDER BY a.date DESC, a.platform, a.metric`
+)
+
+// ReviewAnomaly is a day on which a platform's review count or average rating
+// departed from its baseline, e.g. review bombing or a burst of 5-star reviews
+type ReviewAnomaly struct {
+	ID        uuid.UUID                   `json:"id" db:"id"`
+	ProductID uuid.UUID                   `json:"product_id" db:"product_id"`
+	Platform  consts.PlatformType         `json:"platform" db:"platform"`
+	Date      time.Time                   `json:"date" db:"date"`
+	Metric    consts.AnomalyMetricType    `json:"metric" db:"metric"`
+	Direction consts.AnomalyDirectionType `json:"direction" db:"direction"`
+	// Value is the day's review count or average rating
+	Value          float64   `json:"value" db:"value"`
+	BaselineMean   float64   `json:"baseline_mean" db:"baseline_mean"`
+	BaselineStddev float64   `json:"baseline_stddev" db:"baseline_stddev"`
+	ZScore         float64   `json:"z_score" db:"z_score"`
+	ReviewCount    int64     `json:"review_count" db:"review_count"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReplaceReviewAnomalies replaces the product's anomalies on or after
+// dateFrom ("2006-01-02") with the given ones
+func ReplaceReviewAnomalies(ctx context.Context, productID uuid.UUID, dateFrom string, anomalies []*ReviewAnomaly) error {
+	if anomalies == nil {
+		anomalies = []*ReviewAnomaly{}
+	}
+
+	anomaliesJSON, err := json.Marshal(anomalies)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NamedExecContext(ctx, queryReplaceReviewAnomalies, map[string]interface{}{
+		"product_id": productID,
+		"date_from":  dateFrom,
+		"anomalies":  string(anomaliesJSON),
+	})
+	if err != nil {
+		log.Error("Error while replacing review anomalies", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetReviewAnomalies returns the product's anomalies in the time period,
+// newest first. Platform "all" returns the anomalies of every platform.
+func GetReviewAnomalies(ctx context.Context, productID uuid.UUID, platform consts.PlatformType, timePeriod consts.TimePeriodType) ([]*ReviewAnomaly, error) {
+	anomalies := make([]*ReviewAnomaly, 0)
+
+	dateFrom, dateTo := getDateFromAndDateTo(timePeriod)
+
+	err := db.NamedSelectContext(ctx, &anomalies, queryGetReviewAnomalies, map[string]interface{}{
+		"product_id": productID,
+		"platform":   platform,
+		"date_from":  dateFrom,
+		"date_to":    dateTo,
+	})
+	if err != nil {
+		log.Error("Error while fetching review anomalies", err)
+		return nil, err
+	}
+
+	return anomalies, nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Stores the scores of many reviews in one statement. :scores is a JSON
+	// array of ReviewSuspicion.
+	queryUpdateReviewSuspicion = `
+	UPDATE reviews r
+	SET suspicion_score = s.score,
+		suspicion_reasons = ARRAY(SELECT jsonb_array_elements_text(s.reasons)),
+		suspicion_llm_score = s.llm_score,
+		suspicion_scored_at = NOW()
+	FROM jsonb_to_recordset(CAST(:scores AS JSONB)) AS s(id UUID, score DOUBLE PRECISION, reasons JSONB, llm_score DOUBLE PRECISION)
+	WHERE r.id = s.id`
+)
+
+// ReviewSuspicion is how likely a review is fake or spam and why
+type ReviewSuspicion struct {
+	ReviewID uuid.UUID `json:"id"`
+	Score    float64   `json:"score"`
+	Reasons  []string  `json:"reasons"`
+	// LLMScore is the LLM's own judgment, nil when it was not asked
+	LLMScore *float64 `json:"llm_score"`
+}
+
+func UpdateReviewSuspicion(ctx context.Context, scores []*ReviewSuspicion) error {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	for _, score := range scores {
+		if score.Reasons == nil {
+			score.Reasons = []string{}
+		}
+	}
+
+	scoresJSON, err := json.Marshal(scores)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NamedExecContext(ctx, queryUpdateReviewSuspicion, map[string]interface{}{
+		"scores": string(scoresJSON),
+	})
+	if err != nil {
+		log.Error("Error while updating review suspicion scores", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+const (
+	queryUpsertScrapeCursor = `
+	INSERT INTO scrape_cursors(platform_id, mode, cursor, created_at, updated_at)
+	VALUES(:platform_id, :mode, :cursor, NOW(), NOW())
+	ON CONFLICT (platform_id, mode) DO UPDATE
+	SET cursor = :cursor,
+		updated_at = NOW()`
+
+	queryGetScrapeCursor = `
+	SELECT sc.platform_id, sc.mode, sc.cursor, sc.created_at, sc.updated_at
+	FROM scrape_cursors sc
+	WHERE sc.platform_id = :platform_id AND sc.mode = :mode`
+
+	queryDeleteScrapeCursor = `
+	DELETE FROM scrape_cursors
+	WHERE platform_id = :platform_id AND mode = :mode`
+)
+
+// ScrapeCursor stores where a scraper stopped for a platform. Cursor is opaque
+// to everything except the scraper that produced it, and each mode keeps its
+// own cursor so backfills and incremental syncs never overwrite each other.
+type ScrapeCursor struct {
+	PlatformID uuid.UUID             `json:"platform_id" db:"platform_id"`
+	Mode       consts.ScrapeModeType `json:"mode" db:"mode"`
+	Cursor     string                `json:"cursor" db:"cursor"`
+	CreatedAt  time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time             `json:"updated_at" db:"updated_at"`
+}
+
+func UpsertScrapeCursor(ctx context.Context, cursor *ScrapeCursor) error {
+	_, err := db.NamedExecContext(ctx, queryUpsertScrapeCursor, cursor)
+	if err != nil {
+		log.Error("Error while upserting scrape cursor", err)
+		return err
+	}
+
+	return nil
+}
+
+func GetScrapeCursor(ctx context.Context, platformID uuid.UUID, mode consts.ScrapeModeType) (*ScrapeCursor, error) {
+	var cursor ScrapeCursor
+
+	err := db.NamedGetContext(ctx, &cursor, queryGetScrapeCursor, map[string]interface{}{
+		"platform_id": platformID,
+		"mode":        mode,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		log.Error("Error while fetching scrape cursor", err)
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+func DeleteScrapeCursor(ctx context.Context, platformID uuid.UUID, mode consts.ScrapeModeType) error {
+	_, err := db.NamedExecContext(ctx, queryDeleteScrapeCursor, map[string]interface{}{
+		"platform_id": platformID,
+		"mode":        mode,
+	})
+	if err != nil {
+		log.Error("Error while deleting scrape cursor", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	querySelectSentimentCategoriesByProductID = `
+	SELECT sc.id, sc.product_id, sc.name, sc.description, sc.example_phrases, sc.position, sc.created_at, sc.updated_at
+	FROM sentiment_categories sc
+	WHERE sc.product_id = :product_id
+	ORDER BY sc.position`
+
+	// Replaces the product's categories in one statement. :categories is a
+	// JSON array of {name, description, example_phrases} in display order.
+	queryReplaceSentimentCategories = `
+	WITH deleted AS (
+		DELETE FROM sentiment_categories
+		WHERE product_id = :product_id
+	)
+	INSERT INTO sentiment_categories(product_id, name, description, example_phrases, position, created_at, updated_at)
+	SELECT
+		:product_id,
+		c.category->>'name',
+		COALESCE(c.category->>'description', ''),
+		ARRAY(SELECT jsonb_array_elements_text(COALESCE(c.category->'example_phrases', CAST('[]' AS JSONB)))),
+		c.position - 1,
+		NOW(),
+		NOW()
+	FROM jsonb_array_elements(CAST(:categories AS JSONB)) WITH ORDINALITY AS c(category, position)`
+)
+
+type SentimentCategory struct {
+	ID             uuid.UUID      `json:"id" db:"id"`
+	ProductID      uuid.UUID      `json:"product_id" db:"product_id"`
+	Name           string         `json:"name" db:"name"`
+	Description    string         `json:"description" db:"description"`
+	ExamplePhrases pq.StringArray `json:"example_phrases" db:"example_phrases"`
+	Position       int            `json:"position" db:"position"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// GetSentimentCategoriesByProductID returns the product's categories in
+// order, or an empty slice if it has none
+func GetSentimentCategoriesByProductID(ctx context.Context, productID uuid.UUID) ([]*SentimentCategory, error) {
+	categories := make([]*SentimentCategory, 0)
+
+	err := db.NamedSelectContext(ctx, &categories, querySelectSentimentCategoriesByProductID, map[string]interface{}{
+		"product_id": productID,
+	})
+	if err != nil {
+		log.Error("Error while fetching sentiment categories by product id", err)
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// ReplaceSentimentCategories replaces all of the product's categories with
+// the given ones, kept in the given order
+func ReplaceSentimentCategories(ctx context.Context, productID uuid.UUID, categories []*SentimentCategory) error {
+	categoriesJSON, err := json.Marshal(categories)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NamedExecContext(ctx, queryReplaceSentimentCategories, map[string]interface{}{
+		"product_id": productID,
+		"categories": string(categoriesJSON),
+	})
+	if err != nil {
+		log.Error("Error while replacing sentiment categories", err)
+		return err
+	}
+
+	return nil
+}
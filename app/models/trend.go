@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+const (
+	// Buckets the product's reviews per platform and, as platform 'all',
+	// across platforms. Buckets without reviews are filled in over the whole
+	// date range so moving averages span equal time.
+	queryGetReviewTrends = `
+	WITH filtered AS (
+		SELECT p.name AS platform, date_trunc(:interval, r.date_published) AS bucket, r.rating_value
+		FROM reviews r
+		INNER JOIN platforms p ON p.id = r.platform_id
+		INNER JOIN products pr ON pr.id = p.product_id
+		WHERE pr.id = :product_id AND pr.is_deleted = FALSE %s
+		AND (:platform IN ('', 'all') OR p.name = :platform)
+		AND r.date_published >= CAST(:date_from AS DATE) AND r.date_published < CAST(:date_to AS DATE) + 1
+	),
+	buckets AS (
+		SELECT
+			COALESCE(platform, 'all') AS platform,
+			bucket,
+			COUNT(*) AS review_count,
+			SUM(rating_value) AS rating_total,
+			COUNT(*) FILTER (WHERE CAST(rating_value AS INTEGER) = 1) AS rating_1,
+			COUNT(*) FILTER (WHERE CAST(rating_value AS INTEGER) = 2) AS rating_2,
+			COUNT(*) FILTER (WHERE CAST(rating_value AS INTEGER) = 3) AS rating_3,
+			COUNT(*) FILTER (WHERE CAST(rating_value AS INTEGER) = 4) AS rating_4,
+			COUNT(*) FILTER (WHERE CAST(rating_value AS INTEGER) = 5) AS rating_5
+		FROM filtered
+		GROUP BY GROUPING SETS ((platform, bucket), (bucket))
+	),
+	series AS (
+		SELECT platform, generate_series(
+			date_trunc(:interval, CAST(:date_from AS TIMESTAMP)),
+			date_trunc(:interval, CAST(:date_to AS TIMESTAMP)),
+			CAST('1 ' || :interval AS INTERVAL)
+		) AS bucket
+		FROM (SELECT DISTINCT platform FROM buckets) platforms
+	)
+	SELECT
+		s.platform,
+		s.bucket AS bucket_start,
+		COALESCE(b.review_count, 0) AS review_count,
+		COALESCE(b.rating_total / b.review_count, 0) AS average_rating,
+		COALESCE(b.rating_1, 0) AS rating_1,
+		COALESCE(b.rating_2, 0) AS rating_2,
+		COALESCE(b.rating_3, 0) AS rating_3,
+		COALESCE(b.rating_4, 0) AS rating_4,
+		COALESCE(b.rating_5, 0) AS rating_5,
+		AVG(COALESCE(b.review_count, 0)) OVER w AS moving_average_count,
+		COALESCE(SUM(b.rating_total) OVER w / NULLIF(SUM(b.review_count) OVER w, 0), 0) AS moving_average_rating
+	FROM series s
+	LEFT JOIN buckets b ON b.platform = s.platform AND b.bucket = s.bucket
+	-- A single platform's 'all' series would only repeat it
+	WHERE :platform IN ('', 'all') OR s.platform <> 'all'
+	WINDOW w AS (PARTITION BY s.platform ORDER BY s.bucket ROWS BETWEEN CAST(:window AS INTEGER) - 1 PRECEDING AND CURRENT ROW)
+	ORDER BY s.platform, s.bucket`
+
+	// Limits review trends to products in the user's organizations
+	queryReviewTrendsUserScope = `AND pr.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = :user_id)`
+)
+
+// TrendBucket is the reviews of one platform published in one day, week or
+// month. Moving averages cover the bucket and the buckets before it.
+type TrendBucket struct {
+	Platform            consts.PlatformType `json:"-" db:"platform"`
+	BucketStart         time.Time           `json:"bucket_start" db:"bucket_start"`
+	ReviewCount         int64               `json:"review_count" db:"review_count"`
+	AverageRating       float64             `json:"average_rating" db:"average_rating"`
+	RatingDistribution  []*ReviewRating     `json:"rating_distribution" db:"-"`
+	MovingAverageCount  float64             `json:"moving_average_count" db:"moving_average_count"`
+	MovingAverageRating float64             `json:"moving_average_rating" db:"moving_average_rating"`
+
+	Rating1 int64 `json:"-" db:"rating_1"`
+	Rating2 int64 `json:"-" db:"rating_2"`
+	Rating3 int64 `json:"-" db:"rating_3"`
+	Rating4 int64 `json:"-" db:"rating_4"`
+	Rating5 int64 `json:"-" db:"rating_5"`
+}
+
+// PlatformTrend is a platform's buckets in order. Platform "all" combines
+// every platform of the product.
+type PlatformTrend struct {
+	Platform consts.PlatformType `json:"platform"`
+	Buckets  []*TrendBucket      `json:"buckets"`
+}
+
+// ReviewTrendQuery selects the reviews GetReviewTrends buckets. Interval is
+// "day", "week" or "month", Window the number of buckets moving averages
+// cover, and dates are "2006-01-02" and inclusive.
+type ReviewTrendQuery struct {
+	ProductID uuid.UUID
+	UserID    uuid.UUID
+	Platform  consts.PlatformType
+	Interval  string
+	Window    int
+	DateFrom  string
+	DateTo    string
+}
+
+// GetReviewTrends returns the trends of a product in one of query.UserID's
+// organizations
+func GetReviewTrends(ctx context.Context, query ReviewTrendQuery) ([]*PlatformTrend, error) {
+	return getReviewTrends(ctx, query, queryReviewTrendsUserScope)
+}
+
+// GetProductReviewTrends returns the trends of the product whoever asks, for
+// background jobs that do not act for a user. query.UserID is ignored.
+func GetProductReviewTrends(ctx context.Context, query ReviewTrendQuery) ([]*PlatformTrend, error) {
+	return getReviewTrends(ctx, query, "")
+}
+
+func getReviewTrends(ctx context.Context, query ReviewTrendQuery, userScope string) ([]*PlatformTrend, error) {
+	buckets := make([]*TrendBucket, 0)
+
+	err := db.NamedSelectContext(ctx, &buckets, fmt.Sprintf(queryGetReviewTrends, userScope), map[string]interface{}{
+		"product_id": query.ProductID,
+		"user_id":    query.UserID,
+		"platform":   query.Platform,
+		"interval":   query.Interval,
+		"window":     query.Window,
+		"date_from":  query.DateFrom,
+		"date_to":    query.DateTo,
+	})
+	if err != nil {
+		log.Error("Error while fetching review trends", err)
+		return nil, err
+	}
+
+	trends := make([]*PlatformTrend, 0)
+	for _, bucket := range buckets {
+		bucket.RatingDistribution = []*ReviewRating{
+			{Rating: 1, Count: bucket.Rating1},
+			{Rating: 2, Count: bucket.Rating2},
+			{Rating: 3, Count: bucket.Rating3},
+			{Rating: 4, Count: bucket.Rating4},
+			{Rating: 5, Count: bucket.Rating5},
+		}
+
+		if len(trends) == 0 || trends[len(trends)-1].Platform != bucket.Platform {
+			trends = append(trends, &PlatformTrend{Platform: bucket.Platform, Buckets: []*TrendBucket{}})
+		}
+		trend := trends[len(trends)-1]
+		trend.Buckets = append(trend.Buckets, bucket)
+	}
+
+	return trends, nil
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	queryCountProductsByUserID = `
+	SELECT COUNT(*)
+	FROM products p
+	WHERE p.user_id = :user_id AND p.is_deleted = FALSE`
+
+	queryCountPlatformsByProductID = `
+	SELECT COUNT(*)
+	FROM platforms p
+	WHERE p.product_id = :product_id`
+
+	queryCountReviewsByUserID = `
+	SELECT COUNT(*)
+	FROM reviews r
+	INNER JOIN platforms p ON p.id = r.platform_id
+	INNER JOIN products pr ON pr.id = p.product_id
+	WHERE pr.user_id = :user_id AND pr.is_deleted = FALSE`
+
+	queryInsertStatsGeneration = `
+	INSERT INTO stats_generations(id, user_id, product_id, created_at)
+	VALUES(:id, :user_id, :product_id, NOW())`
+
+	queryCountStatsGenerationsSince = `
+	SELECT COUNT(*)
+	FROM stats_generations sg
+	WHERE sg.user_id = :user_id AND sg.created_at >= :since`
+
+	// Held until the transaction ends, so a user's generations are counted
+	// and recorded one request at a time
+	queryLockUserStatsGenerations = `SELECT pg_advisory_xact_lock(CAST(:lock_key AS BIGINT))`
+)
+
+// ErrStatsGenerationLimit is returned when the user already used the limit
+var ErrStatsGenerationLimit = errors.New("stats generation limit reached")
+
+// CountProductsByUserID counts the live products the user created
+func CountProductsByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+
+	err := db.NamedGetContext(ctx, &count, queryCountProductsByUserID, map[string]interface{}{
+		"user_id": userID,
+	})
+	if err != nil {
+		log.Error("Error while counting products by user id", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func CountPlatformsByProductID(ctx context.Context, productID uuid.UUID) (int, error) {
+	var count int
+
+	err := db.NamedGetContext(ctx, &count, queryCountPlatformsByProductID, map[string]interface{}{
+		"product_id": productID,
+	})
+	if err != nil {
+		log.Error("Error while counting platforms by product id", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CountReviewsByUserID counts the reviews stored for the live products the user created
+func CountReviewsByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+
+	err := db.NamedGetContext(ctx, &count, queryCountReviewsByUserID, map[string]interface{}{
+		"user_id": userID,
+	})
+	if err != nil {
+		log.Error("Error while counting reviews by user id", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CreateStatsGenerationWithinLimit records a stats generation unless the
+// user already has limit of them since since, in which case it returns
+// ErrStatsGenerationLimit. A negative limit records unconditionally. It
+// returns the generations used before this one.
+func CreateStatsGenerationWithinLimit(ctx context.Context, userID, productID uuid.UUID, since time.Time, limit int) (int, error) {
+	var used int
+
+	err := db.InTransaction(ctx, func(tx *sqlx.Tx) error {
+		// The first half of the user ID is as unique as a lock key needs to be
+		_, err := tx.NamedExecContext(ctx, queryLockUserStatsGenerations, map[string]interface{}{
+			"lock_key": int64(binary.BigEndian.Uint64(userID[:8])),
+		})
+		if err != nil {
+			return err
+		}
+
+		params := map[string]interface{}{
+			"id":         uuid.New(),
+			"user_id":    userID,
+			"product_id": productID,
+			"since":      since,
+		}
+
+		query, args, err := tx.BindNamed(queryCountStatsGenerationsSince, params)
+		if err != nil {
+			return err
+		}
+		if err := tx.GetContext(ctx, &used, query, args...); err != nil {
+			return err
+		}
+
+		if limit >= 0 && used >= limit {
+			return ErrStatsGenerationLimit
+		}
+
+		_, err = tx.NamedExecContext(ctx, queryInsertStatsGeneration, params)
+		return err
+	})
+	if err != nil && err != ErrStatsGenerationLimit {
+		log.Error("Error while recording stats generation", err)
+	}
+
+	return used, err
+}
+
+func CountStatsGenerationsSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+
+	err := db.NamedGetContext(ctx, &count, queryCountStatsGenerationsSince, map[string]interface{}{
+		"user_id": userID,
+		"since":   since,
+	})
+	if err != nil {
+		log.Error("Error while counting stats generations", err)
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/fatih/structs"
 	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
 )
 
 const (
@@ -16,33 +17,58 @@ const (
 		VALUES(:id, :clerk_id, :name, :email, NOW(), NOW())`
 
 	queryGetUserByID = `
-		SELECT u.id, u.name, u.email, u.created_at
+		SELECT u.id, u.name, u.email, u.plan, u.created_at
 		FROM users u
-		WHERE u.id = :id`
+		WHERE u.id = :id AND u.is_deleted = FALSE`
 
 	queryGetUserByClerkID = `
-		SELECT u.id, u.clerk_id, u.name, u.email, u.created_at
+		SELECT u.id, u.clerk_id, u.name, u.email, u.plan, u.created_at
 		FROM users u
-		WHERE u.clerk_id = :clerk_id`
+		WHERE u.clerk_id = :clerk_id AND u.is_deleted = FALSE`
 
 	queryGetUserByEmail = `
-		SELECT u.id, u.name, u.email, u.created_at
+		SELECT u.id, u.name, u.email, u.plan, u.created_at
 		FROM users u
-		WHERE email = :email`
+		WHERE email = :email AND u.is_deleted = FALSE`
 
 	queryUpdateUserByID = `
-		UPDATE users SET %s
+		UPDATE users SET %s, updated_at = NOW()
 		WHERE id = :id
-		RETURNING *`
+		RETURNING id, clerk_id, name, email, plan, created_at, updated_at`
+
+	// Soft deletes the user, removes their memberships and soft deletes the
+	// products of organizations left without members, in one statement
+	querySoftDeleteUser = `
+		WITH deleted_user AS (
+			UPDATE users
+			SET is_deleted = TRUE, deleted_at = NOW(), updated_at = NOW()
+			WHERE id = :id
+			RETURNING id
+		), removed_membership AS (
+			DELETE FROM organization_members
+			WHERE user_id IN (SELECT id FROM deleted_user)
+			RETURNING organization_id
+		)
+		UPDATE products
+		SET is_deleted = TRUE, updated_at = NOW()
+		WHERE organization_id IN (SELECT organization_id FROM removed_membership)
+		AND NOT EXISTS (
+			SELECT 1 FROM organization_members om
+			WHERE om.organization_id = products.organization_id
+			AND om.user_id NOT IN (SELECT id FROM deleted_user)
+		)`
 )
 
+// The structs tags name the columns UpdateUser writes. Plan is changed by
+// billing only, never through UpdateUser.
 type User struct {
-	ID        uuid.UUID `db:"id" json:"id"`
-	ClerkID   string    `db:"clerk_id" json:"clerk_id"`
-	Name      string    `db:"name" json:"name"`
-	Email     string    `db:"email" json:"email" validate:"required,email"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	ID        uuid.UUID       `db:"id" json:"id" structs:"id"`
+	ClerkID   string          `db:"clerk_id" json:"clerk_id" structs:"clerk_id"`
+	Name      string          `db:"name" json:"name" structs:"name"`
+	Email     string          `db:"email" json:"email" validate:"required,email" structs:"email"`
+	Plan      consts.PlanType `db:"plan" json:"plan" structs:"-"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at" structs:"-"`
+	UpdatedAt time.Time       `db:"updated_at" json:"updated_at" structs:"-"`
 }
 
 func CreateUser(ctx context.Context, user *User) error {
@@ -121,3 +147,15 @@ func UpdateUser(ctx context.Context, user *User) error {
 
 	return nil
 }
+
+func SoftDeleteUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := db.NamedExecContext(ctx, querySoftDeleteUser, map[string]interface{}{
+		"id": userID,
+	})
+	if err != nil {
+		log.Error("Error while soft deleting user", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+)
+
+const (
+	queryInsertWebhookEvent = `
+	INSERT INTO webhook_events(id, source, type, received_at)
+	VALUES(:id, :source, :type, NOW())
+	ON CONFLICT (id) DO NOTHING`
+
+	queryDeleteWebhookEvent = `
+	DELETE FROM webhook_events
+	WHERE id = :id`
+)
+
+// RecordWebhookEvent stores a webhook message ID and reports false when the
+// message was already recorded, i.e. it is a redelivery.
+func RecordWebhookEvent(ctx context.Context, id, source, eventType string) (bool, error) {
+	result, err := db.NamedExecContext(ctx, queryInsertWebhookEvent, map[string]interface{}{
+		"id":     id,
+		"source": source,
+		"type":   eventType,
+	})
+	if err != nil {
+		log.Error("Error while recording webhook event", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// DeleteWebhookEvent forgets a webhook message so a redelivery is processed again
+func DeleteWebhookEvent(ctx context.Context, id string) error {
+	_, err := db.NamedExecContext(ctx, queryDeleteWebhookEvent, map[string]interface{}{
+		"id": id,
+	})
+	if err != nil {
+		log.Error("Error while deleting webhook event", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"slices"
+
+	"github.com/review-aggregator/review-api/app/consts"
+)
+
+// Action is something a user can do to a product
+type Action string
+
+const (
+	ActionViewProduct   Action = "product:view"
+	ActionUpdateProduct Action = "product:update"
+	ActionDeleteProduct Action = "product:delete"
+	ActionViewStats     Action = "stats:view"
+	ActionGenerateStats Action = "stats:generate"
+	ActionViewReplies   Action = "replies:view"
+	ActionDraftReplies  Action = "replies:draft"
+	ActionAskReviews    Action = "reviews:ask"
+)
+
+// rolePermissions lists the actions each organization role may take on the
+// organization's products
+var rolePermissions = map[consts.OrganizationRoleType][]Action{
+	consts.OrganizationRoleOwner: {
+		ActionViewProduct, ActionUpdateProduct, ActionDeleteProduct,
+		ActionViewStats, ActionGenerateStats,
+		ActionViewReplies, ActionDraftReplies,
+		ActionAskReviews,
+	},
+	consts.OrganizationRoleAdmin: {
+		ActionViewProduct, ActionUpdateProduct,
+		ActionViewStats, ActionGenerateStats,
+		ActionViewReplies, ActionDraftReplies,
+		ActionAskReviews,
+	},
+	consts.OrganizationRoleViewer: {
+		ActionViewProduct,
+		ActionViewStats,
+		ActionViewReplies,
+		ActionAskReviews,
+	},
+}
+
+// Can reports whether a member with role may take action on a product
+func Can(role consts.OrganizationRoleType, action Action) bool {
+	return slices.Contains(rolePermissions[role], action)
+}
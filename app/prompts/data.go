@@ -0,0 +1,86 @@
+package prompts
+
+// ReviewData is a review as shown to the model
+type ReviewData struct {
+	RatingValue float64
+	Headline    string
+	ReviewBody  string
+}
+
+type SummaryData struct {
+	ProductDescription string
+	Reviews            []ReviewData
+	// MaxItems limits the number of key highlights and pain points
+	MaxItems int
+}
+
+// CategoryData is a sentiment category with the hints shown to the model
+type CategoryData struct {
+	Name           string
+	Description    string
+	ExamplePhrases []string
+}
+
+type SentimentData struct {
+	ProductDescription string
+	Reviews            []ReviewData
+	// Categories are the category names, CategoryDetails the same categories
+	// with their descriptions for versions that use them
+	Categories      []string
+	CategoryDetails []CategoryData
+}
+
+type ReplyData struct {
+	ProductDescription string
+	Review             ReviewData
+	Tone               string
+	Guidelines         string
+	Signature          string
+	MaxWords           int
+}
+
+// CitedReviewData is a review shown with the ID answers cite it by
+type CitedReviewData struct {
+	ID            string
+	DatePublished string
+	RatingValue   float64
+	Headline      string
+	ReviewBody    string
+}
+
+type AnswerData struct {
+	ProductDescription string
+	Reviews            []CitedReviewData
+	Question           string
+}
+
+// SentimentCountData is a category's sentiment counts
+type SentimentCountData struct {
+	Category  string
+	Positive  int
+	Negative  int
+	NoOpinion int
+}
+
+// ComparedProductData is a product's review statistics
+type ComparedProductData struct {
+	Name          string
+	Description   string
+	ReviewCount   int64
+	AverageRating float64
+	KeyHighlights []string
+	PainPoints    []string
+	Sentiment     []SentimentCountData
+}
+
+type CompareData struct {
+	// Products lists the team's own product first, then its competitors
+	Products []ComparedProductData
+	MaxWords int
+}
+
+// SuspicionData is the reviews the LLM judges for signs of being fake
+type SuspicionData struct {
+	ProductDescription string
+	Reviews            []CitedReviewData
+}
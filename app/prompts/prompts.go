@@ -0,0 +1,152 @@
+// Package prompts is the registry of the LLM prompt templates. Each prompt
+// lives in templates/<name>/<version>.tmpl and defines a "system" and a
+// "user" template. New wording goes in a new version so stats can be traced
+// back to the prompt that produced them.
+package prompts
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/review-aggregator/review-api/app/config"
+)
+
+const (
+	Summary   = "summary"
+	Sentiment = "sentiment"
+	Reply     = "reply"
+	Answer    = "answer"
+	Compare   = "compare"
+	Suspicion = "suspicion"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// sharedTemplates are available to every prompt, e.g. {{template "reviews" .Reviews}}
+const sharedTemplates = "templates/reviews.tmpl"
+
+var funcs = template.FuncMap{
+	"join": strings.Join,
+}
+
+type Prompt struct {
+	Name     string
+	Version  string
+	template *template.Template
+}
+
+// ID names the prompt and version, e.g. "summary/v1", as recorded on product_stats
+func (p *Prompt) ID() string {
+	return p.Name + "/" + p.Version
+}
+
+// Render executes the prompt's system and user templates with data
+func (p *Prompt) Render(data interface{}) (system, user string, err error) {
+	var systemBuilder, userBuilder strings.Builder
+
+	if err := p.template.ExecuteTemplate(&systemBuilder, "system", data); err != nil {
+		return "", "", fmt.Errorf("error rendering %s system prompt: %w", p.ID(), err)
+	}
+	if err := p.template.ExecuteTemplate(&userBuilder, "user", data); err != nil {
+		return "", "", fmt.Errorf("error rendering %s user prompt: %w", p.ID(), err)
+	}
+
+	return systemBuilder.String(), userBuilder.String(), nil
+}
+
+// Messages renders the prompt as chat messages for the LLM API
+func (p *Prompt) Messages(data interface{}) ([]map[string]string, error) {
+	system, user, err := p.Render(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []map[string]string{
+		{"role": "system", "content": system},
+		{"role": "user", "content": user},
+	}, nil
+}
+
+// registry maps prompt name to version to prompt
+var registry = mustLoadRegistry()
+
+func mustLoadRegistry() map[string]map[string]*Prompt {
+	registry := map[string]map[string]*Prompt{}
+
+	files, err := fs.Glob(templateFS, "templates/*/*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, file := range files {
+		name := path.Base(path.Dir(file))
+		version := strings.TrimSuffix(path.Base(file), ".tmpl")
+
+		tmpl, err := template.New(name).Funcs(funcs).ParseFS(templateFS, sharedTemplates, file)
+		if err != nil {
+			panic(fmt.Sprintf("error parsing prompt %s/%s: %v", name, version, err))
+		}
+
+		if registry[name] == nil {
+			registry[name] = map[string]*Prompt{}
+		}
+		registry[name][version] = &Prompt{Name: name, Version: version, template: tmpl}
+	}
+
+	return registry
+}
+
+// Get returns the version of the prompt pinned in config.Config.PromptVersions,
+// or its latest version
+func Get(name string) (*Prompt, error) {
+	if version := config.Config.PromptVersions[name]; version != "" {
+		return GetVersion(name, version)
+	}
+
+	versions := Versions(name)
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("unknown prompt %q", name)
+	}
+
+	return registry[name][versions[len(versions)-1]], nil
+}
+
+func GetVersion(name, version string) (*Prompt, error) {
+	prompt, ok := registry[name][version]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt version %s/%s", name, version)
+	}
+
+	return prompt, nil
+}
+
+// Versions lists the prompt's versions from oldest to newest
+func Versions(name string) []string {
+	versions := make([]string, 0, len(registry[name]))
+	for version := range registry[name] {
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versionNumber(versions[i]) < versionNumber(versions[j])
+	})
+
+	return versions
+}
+
+// versionNumber orders "v2" before "v10"
+func versionNumber(version string) int {
+	number, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return -1
+	}
+
+	return number
+}
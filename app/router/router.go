@@ -5,8 +5,10 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/review-aggregator/review-api/app/consts"
 	"github.com/review-aggregator/review-api/app/handlers"
 	"github.com/review-aggregator/review-api/app/middleware"
+	"github.com/review-aggregator/review-api/app/policy"
 )
 
 func CORSMiddleware() gin.HandlerFunc {
@@ -35,30 +37,72 @@ func SetupRouter() *gin.Engine {
 
 	apiRouter := router.Group("/api")
 
+	// API keys are refused on routes that do not declare the scope they need
+	readReviews := middleware.RequireScope(consts.APIKeyScopeReadReviews)
+	writeProducts := middleware.RequireScope(consts.APIKeyScopeWriteProducts)
+	generateStats := middleware.RequireScope(consts.APIKeyScopeGenerateStats)
+
 	// User routes group
 	userGroup := apiRouter.Group("/users")
 	userGroup.POST("/clerk/webhook", handlers.HandlerSignUpClerkWebhook)
 	userGroup.Use(middleware.AuthMiddleware())
-	userGroup.GET("", handlers.HandlerGetUser)
+	userGroup.GET("", readReviews, handlers.HandlerGetUser)
+	userGroup.GET("/usage", readReviews, handlers.HandlerGetUsage)
+	userGroup.GET("/llm-usage", readReviews, handlers.HandlerGetLLMUsage)
+	userGroup.POST("/api-keys", middleware.RequireSession(), handlers.HandlerCreateAPIKey)
+	userGroup.GET("/api-keys", middleware.RequireSession(), handlers.HandlerGetAPIKeys)
+	userGroup.DELETE("/api-keys/:key_id", middleware.RequireSession(), handlers.HandlerRevokeAPIKey)
+
+	organizationGroup := apiRouter.Group("/organizations")
+	organizationGroup.Use(middleware.AuthMiddleware(), middleware.RequireSession())
+	organizationGroup.POST("", handlers.HandlerCreateOrganization)
+	organizationGroup.GET("", handlers.HandlerGetOrganizations)
+	organizationGroup.GET("/:organization_id/members", handlers.HandlerGetOrganizationMembers)
+	organizationGroup.POST("/:organization_id/members", handlers.HandlerAddOrganizationMember)
+	organizationGroup.PUT("/:organization_id/members/:user_id", handlers.HandlerUpdateOrganizationMember)
+	organizationGroup.DELETE("/:organization_id/members/:user_id", handlers.HandlerRemoveOrganizationMember)
 
 	// Product routes group (protected)
 	productGroup := apiRouter.Group("/product")
-	productGroup.GET("/:product_id/generate-stats", handlers.HandlerGenerateProductStats)
-	productGroup.GET("/:product_id/stats", handlers.HandlerGetProductStats)
-	productGroup.Use(middleware.ClerkMiddleware())
-	productGroup.POST("", handlers.HandlerCreateProduct)
-	productGroup.GET("", handlers.HandlerGetProducts)
-	productGroup.GET("/:product_id", handlers.HandlerGetProductByID)
-	productGroup.PUT("/:product_id", handlers.HandlerUpdateProduct)
-	productGroup.DELETE("/:product_id", handlers.HandlerDeleteProduct)
+	productGroup.Use(middleware.AuthMiddleware())
+	productGroup.POST("", writeProducts, handlers.HandlerCreateProduct)
+	productGroup.GET("", readReviews, handlers.HandlerGetProducts)
+	productGroup.POST("/compare", generateStats, handlers.HandlerCompareProducts)
+
+	// Every route under a product is authorized against the caller's role in
+	// the organization owning it
+	productScope := productGroup.Group("/:product_id")
+	productScope.GET("", readReviews, middleware.ProductPolicy(policy.ActionViewProduct), handlers.HandlerGetProductByID)
+	productScope.PUT("", writeProducts, middleware.ProductPolicy(policy.ActionUpdateProduct), handlers.HandlerUpdateProduct)
+	productScope.DELETE("", writeProducts, middleware.ProductPolicy(policy.ActionDeleteProduct), handlers.HandlerDeleteProduct)
+	productScope.GET("/generate-stats", generateStats, middleware.ProductPolicy(policy.ActionGenerateStats), handlers.HandlerGenerateProductStats)
+	productScope.GET("/stats/stream", generateStats, middleware.ProductPolicy(policy.ActionGenerateStats), handlers.HandlerStreamProductSummary)
+	productScope.GET("/stats", readReviews, middleware.ProductPolicy(policy.ActionViewStats), handlers.HandlerGetProductStats)
+	productScope.GET("/trends", readReviews, middleware.ProductPolicy(policy.ActionViewStats), handlers.HandlerGetProductTrends)
+	productScope.GET("/sentiment-categories", readReviews, middleware.ProductPolicy(policy.ActionViewProduct), handlers.HandlerGetSentimentCategories)
+	productScope.PUT("/sentiment-categories", writeProducts, middleware.ProductPolicy(policy.ActionUpdateProduct), handlers.HandlerUpdateSentimentCategories)
+	productScope.GET("/llm-usage", readReviews, middleware.ProductPolicy(policy.ActionViewStats), handlers.HandlerGetProductLLMUsage)
+	productScope.POST("/ask", generateStats, middleware.ProductPolicy(policy.ActionAskReviews), handlers.HandlerAskProductQuestion)
+	productScope.GET("/conversations", readReviews, middleware.ProductPolicy(policy.ActionAskReviews), handlers.HandlerGetProductConversations)
+	productScope.GET("/conversations/:conversation_id", readReviews, middleware.ProductPolicy(policy.ActionAskReviews), handlers.HandlerGetProductConversation)
+	productScope.GET("/reviews/unanswered", readReviews, middleware.ProductPolicy(policy.ActionViewProduct), handlers.HandlerGetUnansweredNegativeReviews)
+	productScope.GET("/brand-tone", readReviews, middleware.ProductPolicy(policy.ActionViewProduct), handlers.HandlerGetBrandTone)
+	productScope.PUT("/brand-tone", writeProducts, middleware.ProductPolicy(policy.ActionUpdateProduct), handlers.HandlerUpdateBrandTone)
 
 	reviewGroup := apiRouter.Group("/review")
-	reviewGroup.POST("/formatted", handlers.HandlerGetFormattedReviews)
+	reviewGroup.POST("/:review_id/draft-reply", middleware.AuthMiddleware(), writeProducts, middleware.ReviewPolicy(policy.ActionDraftReplies), handlers.HandlerDraftReviewReply)
+	reviewGroup.GET("/:review_id/draft-replies", middleware.AuthMiddleware(), readReviews, middleware.ReviewPolicy(policy.ActionViewReplies), handlers.HandlerGetReviewReplyDrafts)
+	reviewGroup.PUT("/:review_id/draft-replies/:draft_id", middleware.AuthMiddleware(), writeProducts, middleware.ReviewPolicy(policy.ActionDraftReplies), handlers.HandlerUpdateReviewReplyDraft)
 
 	internalGroup := apiRouter.Group("internal")
+	internalGroup.Use(middleware.InternalAuthMiddleware())
 	internalGroup.GET("/platforms/:platform_id/scrape", handlers.HandlerRunPlatformScraper)
+	internalGroup.POST("/platforms/:platform_id/backfill", handlers.HandlerCreateBackfillJob)
+	internalGroup.GET("/backfill-jobs/:job_id", handlers.HandlerGetBackfillJob)
 	internalGroup.POST("/trustpilot/reviews", handlers.HandlerInsertTrustpilotReviews)
 	internalGroup.POST("/product-stats", handlers.HandlerInsertProductStats)
+	// Takes the user and platform from the body, so only services may call it
+	internalGroup.POST("/reviews/formatted", handlers.HandlerGetFormattedReviews)
 
 	return router
 }
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+const (
+	// Anomalies are looked for in the last anomalyDetectionDays days, each day
+	// compared with the anomalyBaselineDays days before it
+	anomalyDetectionDays = 90
+	anomalyBaselineDays  = 28
+	// anomalyMinBaselineDays is how much history a day needs to be judged
+	anomalyMinBaselineDays = 14
+	// anomalyZScore is how many standard deviations from the baseline a day
+	// must be to count as an anomaly
+	anomalyZScore = 3.0
+	// Days with fewer reviews than these are too small to flag, a spike of
+	// three reviews on a quiet product is not review bombing
+	anomalyMinReviewCount       = 5
+	anomalyMinRatedReviewCount  = 3
+	anomalyMinRatingStddev      = 0.5
+	anomalyMinReviewCountStddev = 1.0
+)
+
+// DetectReviewAnomalies scores each platform's daily review counts and
+// average ratings against a rolling baseline of the preceding weeks and
+// replaces the product's stored anomalies for the detection window. It reads
+// all of the product's reviews, independent of who triggered the run.
+func DetectReviewAnomalies(ctx context.Context, productID uuid.UUID) error {
+	now := time.Now().UTC()
+	detectFrom := now.AddDate(0, 0, -anomalyDetectionDays).Format(time.DateOnly)
+
+	trends, err := models.GetProductReviewTrends(ctx, models.ReviewTrendQuery{
+		ProductID: productID,
+		Platform:  consts.PlatformAll,
+		Interval:  "day",
+		Window:    1,
+		DateFrom:  now.AddDate(0, 0, -anomalyDetectionDays-anomalyBaselineDays).Format(time.DateOnly),
+		DateTo:    now.Format(time.DateOnly),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting daily reviews: %w", err)
+	}
+
+	var anomalies []*models.ReviewAnomaly
+	for _, trend := range trends {
+		anomalies = append(anomalies, detectTrendAnomalies(productID, trend, detectFrom)...)
+	}
+
+	return models.ReplaceReviewAnomalies(ctx, productID, detectFrom, anomalies)
+}
+
+// detectTrendAnomalies flags the days from detectFrom on in a platform's
+// gap-free daily buckets. The empty days before the platform's first review
+// are not history, so baselines start at that review.
+func detectTrendAnomalies(productID uuid.UUID, trend *models.PlatformTrend, detectFrom string) []*models.ReviewAnomaly {
+	firstReviewed := slices.IndexFunc(trend.Buckets, func(bucket *models.TrendBucket) bool { return bucket.ReviewCount > 0 })
+	if firstReviewed < 0 {
+		return nil
+	}
+
+	var anomalies []*models.ReviewAnomaly
+	for i, bucket := range trend.Buckets {
+		if i < firstReviewed+anomalyMinBaselineDays || bucket.BucketStart.Format(time.DateOnly) < detectFrom {
+			continue
+		}
+
+		baseline := trend.Buckets[max(firstReviewed, i-anomalyBaselineDays):i]
+		newAnomaly := func(metric consts.AnomalyMetricType, value, mean, stddev float64) *models.ReviewAnomaly {
+			zScore := (value - mean) / stddev
+			if math.Abs(zScore) < anomalyZScore {
+				return nil
+			}
+
+			direction := consts.AnomalyDirectionSpike
+			if zScore < 0 {
+				direction = consts.AnomalyDirectionDrop
+			}
+
+			return &models.ReviewAnomaly{
+				ProductID:      productID,
+				Platform:       trend.Platform,
+				Date:           bucket.BucketStart,
+				Metric:         metric,
+				Direction:      direction,
+				Value:          value,
+				BaselineMean:   mean,
+				BaselineStddev: stddev,
+				ZScore:         zScore,
+				ReviewCount:    bucket.ReviewCount,
+			}
+		}
+
+		// Daily counts are roughly Poisson, so the standard deviation is kept
+		// at least at the square root of the mean for sparse baselines
+		countMean, countStddev := countBaseline(baseline)
+		countStddev = math.Max(countStddev, math.Max(math.Sqrt(countMean), anomalyMinReviewCountStddev))
+		if bucket.ReviewCount >= anomalyMinReviewCount || countMean >= anomalyMinReviewCount {
+			if anomaly := newAnomaly(consts.AnomalyMetricReviewCount, float64(bucket.ReviewCount), countMean, countStddev); anomaly != nil {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+
+		// The day's average rating is compared with the baseline's using the
+		// standard error of a mean of that many ratings
+		ratingMean, ratingStddev, ratedReviews := ratingBaseline(baseline)
+		if bucket.ReviewCount >= anomalyMinRatedReviewCount && ratedReviews >= anomalyMinRatedReviewCount {
+			standardError := math.Max(ratingStddev, anomalyMinRatingStddev) / math.Sqrt(float64(bucket.ReviewCount))
+			if anomaly := newAnomaly(consts.AnomalyMetricAverageRating, bucket.AverageRating, ratingMean, standardError); anomaly != nil {
+				anomaly.BaselineStddev = ratingStddev
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+	}
+
+	return anomalies
+}
+
+// countBaseline returns the mean and standard deviation of the daily counts
+func countBaseline(baseline []*models.TrendBucket) (float64, float64) {
+	var total float64
+	for _, bucket := range baseline {
+		total += float64(bucket.ReviewCount)
+	}
+	mean := total / float64(len(baseline))
+
+	var squares float64
+	for _, bucket := range baseline {
+		squares += math.Pow(float64(bucket.ReviewCount)-mean, 2)
+	}
+
+	return mean, math.Sqrt(squares / float64(len(baseline)))
+}
+
+// ratingBaseline returns the mean and standard deviation of the individual
+// ratings in the baseline, and how many ratings there were
+func ratingBaseline(baseline []*models.TrendBucket) (float64, float64, int64) {
+	var count int64
+	var total float64
+	for _, bucket := range baseline {
+		for _, rating := range bucket.RatingDistribution {
+			count += rating.Count
+			total += float64(rating.Rating * rating.Count)
+		}
+	}
+	if count == 0 {
+		return 0, 0, 0
+	}
+	mean := total / float64(count)
+
+	var squares float64
+	for _, bucket := range baseline {
+		for _, rating := range bucket.RatingDistribution {
+			squares += float64(rating.Count) * math.Pow(float64(rating.Rating)-mean, 2)
+		}
+	}
+
+	return mean, math.Sqrt(squares / float64(count)), count
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+// dailyTrend builds a platform's daily buckets from counts of 4 star reviews
+func dailyTrend(start time.Time, counts []int64) *models.PlatformTrend {
+	trend := &models.PlatformTrend{Platform: consts.PlatformTrustpilot}
+	for day, count := range counts {
+		bucket := &models.TrendBucket{BucketStart: start.AddDate(0, 0, day), ReviewCount: count}
+		if count > 0 {
+			bucket.AverageRating = 4
+			bucket.RatingDistribution = []*models.ReviewRating{{Rating: 4, Count: count}}
+		}
+		trend.Buckets = append(trend.Buckets, bucket)
+	}
+
+	return trend
+}
+
+func TestDetectTrendAnomaliesIgnoresDaysBeforeFirstReview(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// A month without reviews, then a steady 6 a day
+	counts := make([]int64, 60)
+	for day := 30; day < len(counts); day++ {
+		counts[day] = 6
+	}
+
+	anomalies := detectTrendAnomalies(uuid.New(), dailyTrend(start, counts), start.Format(time.DateOnly))
+	if len(anomalies) != 0 {
+		t.Errorf("got %d anomalies on a steady platform, first on %s", len(anomalies), anomalies[0].Date.Format(time.DateOnly))
+	}
+}
+
+func TestDetectTrendAnomaliesFlagsSpike(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	counts := make([]int64, 40)
+	for day := range counts {
+		counts[day] = 6
+	}
+	counts[35] = 40
+
+	anomalies := detectTrendAnomalies(uuid.New(), dailyTrend(start, counts), start.Format(time.DateOnly))
+	if len(anomalies) != 1 {
+		t.Fatalf("got %d anomalies, want 1", len(anomalies))
+	}
+	if anomaly := anomalies[0]; anomaly.Metric != consts.AnomalyMetricReviewCount || anomaly.Direction != consts.AnomalyDirectionSpike || !anomaly.Date.Equal(start.AddDate(0, 0, 35)) {
+		t.Errorf("got %s %s on %s", anomaly.Direction, anomaly.Metric, anomaly.Date.Format(time.DateOnly))
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/prompts"
+)
+
+const (
+	// maxAskReviews limits the reviews a question is answered from
+	maxAskReviews = 30
+	// maxAskHistoryMessages limits the earlier messages of the conversation
+	// sent along with a question
+	maxAskHistoryMessages = 6
+)
+
+// Answer is the LLM's answer to a question about a product's reviews
+type Answer struct {
+	Answer string `json:"answer"`
+	// ReviewIDs are the cited reviews, limited to the ones the question was
+	// asked with
+	ReviewIDs []string `json:"review_ids"`
+	// PromptVersion is the ID of the prompt that produced the answer
+	PromptVersion string `json:"-"`
+}
+
+// RetrieveReviews returns the reviews a question is answered from. Reviews
+// matching the question's words come first; when none match, the newest
+// reviews within the search's filters are used instead.
+func RetrieveReviews(ctx context.Context, search models.ReviewSearch) ([]*models.Review, error) {
+	search.Limit = maxAskReviews
+
+	reviews, err := models.SearchReviews(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reviews) == 0 && search.Query != "" {
+		search.Query = ""
+		return models.SearchReviews(ctx, search)
+	}
+
+	return reviews, nil
+}
+
+// AnswerQuestion answers the question from the reviews, taking the latest
+// messages of the conversation so far into account
+func AnswerQuestion(ctx context.Context, product *models.Product, question string, history []*models.ConversationMessage, reviews []*models.Review) (*Answer, error) {
+	prompt, err := prompts.Get(prompts.Answer)
+	if err != nil {
+		return nil, err
+	}
+
+	data := prompts.AnswerData{
+		ProductDescription: product.Description,
+		Question:           question,
+	}
+	for _, review := range reviews {
+		data.Reviews = append(data.Reviews, prompts.CitedReviewData{
+			ID:            review.ID.String(),
+			DatePublished: reviewDate(review.DatePublished),
+			RatingValue:   review.RatingValue,
+			Headline:      review.Headline,
+			ReviewBody:    review.ReviewBody,
+		})
+	}
+
+	messages, err := prompt.Messages(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Earlier questions and answers go between the system prompt and the question
+	if len(history) > maxAskHistoryMessages {
+		history = history[len(history)-maxAskHistoryMessages:]
+	}
+	conversation := []map[string]string{messages[0]}
+	for _, message := range history {
+		conversation = append(conversation, map[string]string{"role": string(message.Role), "content": message.Content})
+	}
+	conversation = append(conversation, messages[1:]...)
+
+	body, err := callLLMAPI(ctx, conversation, ConfiguredLLMProvider(), os.Getenv("GROQ_API_KEY_ANSWER"), LLMOperationAnswer)
+	if err != nil {
+		return nil, fmt.Errorf("error calling LLM API: %w", err)
+	}
+
+	answer := &Answer{}
+	if err := json.Unmarshal([]byte(body), answer); err != nil {
+		return nil, fmt.Errorf("%w: error unmarshalling answer: %w", ErrInvalidLLMOutput, err)
+	}
+
+	// Models occasionally cite IDs they were not given
+	reviewIDs := make([]string, 0, len(answer.ReviewIDs))
+	for _, reviewID := range answer.ReviewIDs {
+		reviewID = strings.Trim(strings.TrimSpace(reviewID), "[]")
+		if slices.ContainsFunc(reviews, func(review *models.Review) bool { return review.ID.String() == reviewID }) &&
+			!slices.Contains(reviewIDs, reviewID) {
+			reviewIDs = append(reviewIDs, reviewID)
+		}
+	}
+	answer.ReviewIDs = reviewIDs
+	answer.PromptVersion = prompt.ID()
+
+	return answer, nil
+}
+
+// reviewDate shortens a stored timestamp to its date
+func reviewDate(datePublished string) string {
+	if len(datePublished) >= len("2006-01-02") {
+		return datePublished[:len("2006-01-02")]
+	}
+
+	return datePublished
+}
+
+// ConversationTitle shortens a conversation's first question into its title
+func ConversationTitle(question string) string {
+	const maxTitleLength = 80
+
+	title := strings.Join(strings.Fields(question), " ")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+	}
+
+	return title
+}
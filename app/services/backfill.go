@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+const (
+	defaultBackfillTargetReviews    = 500
+	defaultBackfillPageDelaySeconds = 5
+	backfillPageSize                = 20
+)
+
+// StartBackfill creates a backfill job for the platform and runs it in the
+// background. Nil arguments fall back to the defaults; a job with neither
+// target set stops after defaultBackfillTargetReviews reviews.
+func StartBackfill(ctx context.Context, platform *models.Platform, targetReviews *int, targetSince *time.Time, pageDelaySeconds *int) (*models.BackfillJob, error) {
+	job := &models.BackfillJob{
+		PlatformID:       platform.ID,
+		TargetReviews:    targetReviews,
+		TargetSince:      targetSince,
+		PageDelaySeconds: defaultBackfillPageDelaySeconds,
+	}
+
+	if job.TargetReviews == nil && job.TargetSince == nil {
+		defaultTarget := defaultBackfillTargetReviews
+		job.TargetReviews = &defaultTarget
+	}
+
+	if pageDelaySeconds != nil {
+		job.PageDelaySeconds = *pageDelaySeconds
+	}
+
+	if err := models.CreateBackfillJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("error creating backfill job: %w", err)
+	}
+
+	go func() {
+		if err := RunBackfillJob(context.Background(), job); err != nil {
+			log.Error("Error while running backfill job", err)
+		}
+	}()
+
+	return job, nil
+}
+
+// ResumeBackfillJobs restarts jobs that were pending or running when the
+// server stopped. They continue from the platform's backfill cursor.
+func ResumeBackfillJobs(ctx context.Context) error {
+	jobs, err := models.GetActiveBackfillJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting active backfill jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		go func() {
+			if err := RunBackfillJob(ctx, job); err != nil {
+				log.Error("Error while resuming backfill job", err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// RunBackfillJob runs a backfill job until it reaches its target. Tripadvisor
+// is scraped page by page here; Trustpilot is handed to the scraper service,
+// which reports each page back through RecordBackfillPage.
+func RunBackfillJob(ctx context.Context, job *models.BackfillJob) error {
+	platform, err := models.GetPlatformByID(ctx, job.PlatformID)
+	if err != nil {
+		models.FinishBackfillJob(ctx, job.ID, consts.BackfillStatusFailed, err)
+		return fmt.Errorf("error getting platform: %w", err)
+	}
+
+	if err := models.StartBackfillJob(ctx, job.ID); err != nil {
+		return fmt.Errorf("error starting backfill job: %w", err)
+	}
+
+	switch platform.Name {
+	case consts.PlatformTrustpilot:
+		err = runTrustpilotBackfill(ctx, platform, job)
+	case consts.PlatformTripadvisor:
+		err = runTripadvisorBackfill(ctx, platform, job)
+	default:
+		err = fmt.Errorf("unsupported platform: %s", platform.Name)
+	}
+
+	if err != nil {
+		models.FinishBackfillJob(ctx, job.ID, consts.BackfillStatusFailed, err)
+		return err
+	}
+
+	return nil
+}
+
+func runTrustpilotBackfill(ctx context.Context, platform *models.Platform, job *models.BackfillJob) error {
+	cursor, err := models.GetScrapeCursor(ctx, platform.ID, consts.ScrapeModeBackfill)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error getting scrape cursor: %w", err)
+	}
+
+	var cursorValue string
+	if cursor != nil {
+		cursorValue = cursor.Cursor
+	}
+
+	return ScrapeTrustpilotBackfill(ctx, platform, job, cursorValue)
+}
+
+func runTripadvisorBackfill(ctx context.Context, platform *models.Platform, job *models.BackfillJob) error {
+	fetcher := ScraperFetcher()
+	pageDelay := time.Duration(job.PageDelaySeconds) * time.Second
+	reviewsFetched := job.ReviewsFetched
+
+	var since time.Time
+	if job.TargetSince != nil {
+		since = *job.TargetSince
+	}
+
+	for {
+		limit := backfillPageSize
+		if job.TargetReviews != nil {
+			remainingCount := *job.TargetReviews - reviewsFetched
+			if remainingCount <= 0 {
+				break
+			}
+			if remainingCount < limit {
+				limit = remainingCount
+			}
+		}
+
+		reviews, done, err := ScrapeTripadvisorPage(ctx, fetcher, platform, consts.ScrapeModeBackfill, since, limit)
+		if err != nil {
+			return fmt.Errorf("error scraping page: %w", err)
+		}
+
+		reviewsFetched, err = models.AddBackfillJobProgress(ctx, job.ID, len(reviews))
+		if err != nil {
+			return fmt.Errorf("error updating backfill progress: %w", err)
+		}
+
+		if done {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pageDelay):
+		}
+	}
+
+	return models.FinishBackfillJob(ctx, job.ID, consts.BackfillStatusCompleted, nil)
+}
+
+// RecordBackfillPage records a page the scraper service fetched for a
+// backfill job and completes the job once the service is done or the target
+// review count is reached.
+func RecordBackfillPage(ctx context.Context, jobID uuid.UUID, reviews int, done bool) error {
+	job, err := models.GetBackfillJobByID(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("error getting backfill job: %w", err)
+	}
+
+	if job.Status != consts.BackfillStatusRunning {
+		return fmt.Errorf("backfill job is not running")
+	}
+
+	reviewsFetched, err := models.AddBackfillJobProgress(ctx, jobID, reviews)
+	if err != nil {
+		return fmt.Errorf("error updating backfill progress: %w", err)
+	}
+
+	if done || (job.TargetReviews != nil && reviewsFetched >= *job.TargetReviews) {
+		return models.FinishBackfillJob(ctx, jobID, consts.BackfillStatusCompleted, nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/prompts"
+)
+
+const (
+	maxComparisonWords = 250
+	// painPointSimilarity is the share of words two pain points must have in
+	// common to count as the same complaint
+	painPointSimilarity = 0.5
+)
+
+// ProductComparison is one product's side of a comparison
+type ProductComparison struct {
+	Product            *models.Product        `json:"product"`
+	RatingDistribution []*models.ReviewRating `json:"rating_distribution"`
+	ReviewCount        int64                  `json:"review_count"`
+	AverageRating      float64                `json:"average_rating"`
+	// The stored stats of the product, empty when none were generated yet
+	SentimentCount models.SentimentCounts `json:"sentiment_count"`
+	KeyHighlights  []string               `json:"key_highlights"`
+	PainPoints     []string               `json:"pain_points"`
+}
+
+// SharedPainPoint is a complaint found in the stats of several products
+type SharedPainPoint struct {
+	PainPoint  string      `json:"pain_point"`
+	ProductIDs []uuid.UUID `json:"product_ids"`
+}
+
+type Comparison struct {
+	Products         []*ProductComparison `json:"products"`
+	SharedPainPoints []*SharedPainPoint   `json:"shared_pain_points"`
+	// UniquePainPoints maps product ID to the complaints only its reviews have
+	UniquePainPoints     map[uuid.UUID][]string `json:"unique_pain_points"`
+	Summary              string                 `json:"summary"`
+	SummaryPromptVersion string                 `json:"summary_prompt_version"`
+}
+
+// CompareProducts compares the products' ratings and stored stats for the
+// platform and time period and has the LLM summarize the differences. The
+// first product is treated as the team's own, the others as competitors.
+func CompareProducts(ctx context.Context, products []*models.Product, platform consts.PlatformType, timePeriod consts.TimePeriodType) (*Comparison, error) {
+	comparison := &Comparison{}
+	for _, product := range products {
+		productComparison, err := compareProduct(ctx, product, platform, timePeriod)
+		if err != nil {
+			return nil, err
+		}
+		comparison.Products = append(comparison.Products, productComparison)
+	}
+
+	comparison.SharedPainPoints, comparison.UniquePainPoints = groupPainPoints(comparison.Products)
+
+	summary, promptVersion, err := summarizeComparison(ctx, comparison.Products)
+	if err != nil {
+		return nil, err
+	}
+	comparison.Summary = summary
+	comparison.SummaryPromptVersion = promptVersion
+
+	return comparison, nil
+}
+
+func compareProduct(ctx context.Context, product *models.Product, platform consts.PlatformType, timePeriod consts.TimePeriodType) (*ProductComparison, error) {
+	ratings, err := models.GetReviewRatings(ctx, product.ID, platform, timePeriod, SuspicionThreshold(config.Config.ExcludeSuspiciousReviews))
+	if err != nil {
+		return nil, fmt.Errorf("error getting review ratings: %w", err)
+	}
+
+	productComparison := &ProductComparison{
+		Product:            product,
+		RatingDistribution: ratings,
+		SentimentCount:     models.SentimentCounts{},
+		KeyHighlights:      []string{},
+		PainPoints:         []string{},
+	}
+
+	var ratingTotal int64
+	for _, rating := range ratings {
+		productComparison.ReviewCount += rating.Count
+		ratingTotal += rating.Rating * rating.Count
+	}
+	if productComparison.ReviewCount > 0 {
+		productComparison.AverageRating = float64(ratingTotal) / float64(productComparison.ReviewCount)
+	}
+
+	stats, err := models.GetProductStats(ctx, product.ID, platform, timePeriod)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error getting product stats: %w", err)
+	}
+	if stats != nil {
+		productComparison.SentimentCount = stats.SentimentCount
+		productComparison.KeyHighlights = stats.KeyHighlights
+		productComparison.PainPoints = stats.PainPoints
+	}
+
+	return productComparison, nil
+}
+
+// groupPainPoints matches similar pain points across the products. Pain
+// points are written by the LLM for each product separately, so they are
+// compared by the words they share rather than by their exact text.
+func groupPainPoints(products []*ProductComparison) ([]*SharedPainPoint, map[uuid.UUID][]string) {
+	type painPointGroup struct {
+		painPoint  string
+		words      map[string]bool
+		productIDs []uuid.UUID
+	}
+
+	var groups []*painPointGroup
+	for _, product := range products {
+		for _, painPoint := range product.PainPoints {
+			words := painPointWords(painPoint)
+
+			var match *painPointGroup
+			for _, group := range groups {
+				if wordSimilarity(words, group.words) >= painPointSimilarity {
+					match = group
+					break
+				}
+			}
+
+			if match == nil {
+				match = &painPointGroup{painPoint: painPoint, words: words}
+				groups = append(groups, match)
+			}
+			if len(match.productIDs) == 0 || match.productIDs[len(match.productIDs)-1] != product.Product.ID {
+				match.productIDs = append(match.productIDs, product.Product.ID)
+			}
+		}
+	}
+
+	shared := []*SharedPainPoint{}
+	unique := make(map[uuid.UUID][]string, len(products))
+	for _, product := range products {
+		unique[product.Product.ID] = []string{}
+	}
+
+	for _, group := range groups {
+		if len(group.productIDs) > 1 {
+			shared = append(shared, &SharedPainPoint{PainPoint: group.painPoint, ProductIDs: group.productIDs})
+			continue
+		}
+		productID := group.productIDs[0]
+		unique[productID] = append(unique[productID], group.painPoint)
+	}
+
+	return shared, unique
+}
+
+// painPointWords returns the lowercase words of a pain point that are long
+// enough to carry meaning
+func painPointWords(painPoint string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(painPoint), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) > 3 {
+			words[word] = true
+		}
+	}
+
+	return words
+}
+
+// wordSimilarity is the Jaccard index of two word sets
+func wordSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func summarizeComparison(ctx context.Context, products []*ProductComparison) (string, string, error) {
+	prompt, err := prompts.Get(prompts.Compare)
+	if err != nil {
+		return "", "", err
+	}
+
+	data := prompts.CompareData{MaxWords: maxComparisonWords}
+	for _, product := range products {
+		productData := prompts.ComparedProductData{
+			Name:          product.Product.Name,
+			Description:   product.Product.Description,
+			ReviewCount:   product.ReviewCount,
+			AverageRating: product.AverageRating,
+			KeyHighlights: product.KeyHighlights,
+			PainPoints:    product.PainPoints,
+		}
+		for _, count := range product.SentimentCount {
+			productData.Sentiment = append(productData.Sentiment, prompts.SentimentCountData{
+				Category:  count.Category,
+				Positive:  count.Positive,
+				Negative:  count.Negative,
+				NoOpinion: count.NoOpinion,
+			})
+		}
+		data.Products = append(data.Products, productData)
+	}
+
+	messages, err := prompt.Messages(data)
+	if err != nil {
+		return "", "", err
+	}
+
+	body, err := callLLMAPI(ctx, messages, ConfiguredLLMProvider(), os.Getenv("GROQ_API_KEY_COMPARE"), LLMOperationCompare)
+	if err != nil {
+		return "", "", fmt.Errorf("error calling LLM API: %w", err)
+	}
+
+	return strings.TrimSpace(body), prompt.ID(), nil
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/review-aggregator/review-api/app/config"
+)
+
+// FetcherConfig configures a Fetcher. Zero values fall back to sane defaults,
+// except MaxRetries where zero means a single attempt.
+type FetcherConfig struct {
+	Timeout              time.Duration
+	MaxRetries           int
+	RetryBaseDelay       time.Duration
+	MaxConcurrentPerHost int
+	// Proxies and UserAgents are rotated round-robin per request
+	Proxies    []string
+	UserAgents []string
+	// Cookies maps a host to a Cookie header used to seed the session jar
+	Cookies map[string]string
+	// RecordDir, when set, stores every response there as a fixture
+	RecordDir string
+	// ReplayDir, when set, serves responses from fixtures instead of the network
+	ReplayDir string
+	// Transport replaces the network transport, proxies and fixtures entirely
+	Transport http.RoundTripper
+}
+
+// Fetcher is the HTTP client shared by the scrapers. It retries 429 and 5xx
+// responses with exponential backoff, limits concurrent requests per host,
+// rotates proxies and user agents, and keeps cookies across requests.
+type Fetcher struct {
+	client     *http.Client
+	config     FetcherConfig
+	userAgent  atomic.Uint64
+	proxy      atomic.Uint64
+	hostsMutex sync.Mutex
+	hosts      map[string]chan struct{}
+}
+
+var (
+	scraperFetcher     *Fetcher
+	scraperFetcherOnce sync.Once
+)
+
+// ScraperFetcher returns the Fetcher built from the scraper settings in config.Config
+func ScraperFetcher() *Fetcher {
+	scraperFetcherOnce.Do(func() {
+		fetcherConfig := FetcherConfig{
+			Timeout:              time.Duration(config.Config.ScraperTimeoutSeconds) * time.Second,
+			MaxRetries:           config.Config.ScraperMaxRetries,
+			MaxConcurrentPerHost: config.Config.ScraperMaxConcurrentPerHost,
+			Proxies:              config.Config.ScraperProxies,
+			UserAgents:           config.Config.ScraperUserAgents,
+			Cookies:              config.Config.ScraperCookies,
+		}
+
+		switch config.Config.HTTPFixturesMode {
+		case FixturesModeRecord:
+			fetcherConfig.RecordDir = config.Config.HTTPFixturesDir
+		case FixturesModeReplay:
+			fetcherConfig.ReplayDir = config.Config.HTTPFixturesDir
+		}
+
+		fetcher, err := NewFetcher(fetcherConfig)
+		if err != nil {
+			log.Error("Error while creating scraper fetcher, using defaults", err)
+			fetcher, _ = NewFetcher(FetcherConfig{})
+		}
+		scraperFetcher = fetcher
+	})
+
+	return scraperFetcher
+}
+
+// SetScraperFetcher replaces the fetcher used by the scrapers, e.g. with one
+// replaying fixtures in tests
+func SetScraperFetcher(fetcher *Fetcher) {
+	scraperFetcherOnce.Do(func() {})
+	scraperFetcher = fetcher
+}
+
+func NewFetcher(fetcherConfig FetcherConfig) (*Fetcher, error) {
+	if fetcherConfig.Timeout <= 0 {
+		fetcherConfig.Timeout = 30 * time.Second
+	}
+	if fetcherConfig.RetryBaseDelay <= 0 {
+		fetcherConfig.RetryBaseDelay = time.Second
+	}
+	if fetcherConfig.MaxConcurrentPerHost <= 0 {
+		fetcherConfig.MaxConcurrentPerHost = 2
+	}
+
+	fetcher := &Fetcher{
+		config: fetcherConfig,
+		hosts:  map[string]chan struct{}{},
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cookie jar: %w", err)
+	}
+
+	for host, header := range fetcherConfig.Cookies {
+		cookies, err := http.ParseCookie(header)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookies for host %s: %w", host, err)
+		}
+		jar.SetCookies(&url.URL{Scheme: "https", Host: host}, cookies)
+	}
+
+	roundTripper, err := fetcher.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher.client = &http.Client{
+		Transport: roundTripper,
+		Timeout:   fetcherConfig.Timeout,
+		Jar:       jar,
+	}
+
+	return fetcher, nil
+}
+
+func (f *Fetcher) transport() (http.RoundTripper, error) {
+	if f.config.Transport != nil {
+		return f.config.Transport, nil
+	}
+
+	if f.config.ReplayDir != "" {
+		return NewReplayTransport(f.config.ReplayDir), nil
+	}
+
+	proxies := make([]*url.URL, 0, len(f.config.Proxies))
+	for _, proxy := range f.config.Proxies {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %q: %w", proxy, err)
+		}
+		proxies = append(proxies, proxyURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(proxies) > 0 {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxies[f.proxy.Add(1)%uint64(len(proxies))], nil
+		}
+	}
+
+	if f.config.RecordDir != "" {
+		return NewRecordingTransport(transport, f.config.RecordDir), nil
+	}
+
+	return transport, nil
+}
+
+// Do sends the request, retrying on network errors, 429 and 5xx responses.
+// The per-host slot is held until the returned response body is closed.
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	if len(f.config.UserAgents) > 0 && req.Header.Get("User-Agent") == "" {
+		userAgent := f.config.UserAgents[f.userAgent.Add(1)%uint64(len(f.config.UserAgents))]
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	slot := f.hostSlot(req.URL.Host)
+	select {
+	case slot <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				<-slot
+				return nil, fmt.Errorf("error rewinding request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := f.client.Do(req)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		if !retryable || attempt >= f.config.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			if err != nil {
+				<-slot
+				return nil, err
+			}
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-slot }}
+			return resp, nil
+		}
+
+		delay := f.retryDelay(attempt, resp)
+		if err != nil {
+			log.Warn("Request to", req.URL.Host, "failed, retrying in", delay, ":", err)
+		} else {
+			log.Warn("Request to", req.URL.Host, "returned", resp.StatusCode, ", retrying in", delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(req.Context(), delay); err != nil {
+			<-slot
+			return nil, err
+		}
+	}
+}
+
+func (f *Fetcher) hostSlot(host string) chan struct{} {
+	f.hostsMutex.Lock()
+	defer f.hostsMutex.Unlock()
+
+	slot, ok := f.hosts[host]
+	if !ok {
+		slot = make(chan struct{}, f.config.MaxConcurrentPerHost)
+		f.hosts[host] = slot
+	}
+
+	return slot
+}
+
+// retryDelay honours Retry-After when the server sends it in seconds and
+// otherwise backs off exponentially with jitter.
+func (f *Fetcher) retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	delay := f.config.RetryBaseDelay << attempt
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// releasingBody releases the fetcher's host slot once the body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusServer answers with statuses in order, then keeps repeating the last
+// one, and counts the requests it got
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := int(requests.Add(1)) - 1
+		status := statuses[min(attempt, len(statuses)-1)]
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func newTestFetcher(t *testing.T, maxRetries int) *Fetcher {
+	t.Helper()
+
+	fetcher, err := NewFetcher(FetcherConfig{MaxRetries: maxRetries, RetryBaseDelay: time.Millisecond, MaxConcurrentPerHost: 1})
+	if err != nil {
+		t.Fatalf("NewFetcher: %v", err)
+	}
+
+	return fetcher
+}
+
+// fetch sends a request and fails the test instead of hanging if the host
+// slot was never released
+func fetch(t *testing.T, fetcher *Fetcher, method, url, body string) (*http.Response, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	return fetcher.Do(req)
+}
+
+func TestFetcherRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantStatus   int
+		wantRequests int32
+	}{
+		{"success", []int{200}, 3, 200, 1},
+		{"retries 503", []int{503, 200}, 3, 200, 2},
+		{"retries 429", []int{429, 429, 200}, 3, 200, 3},
+		{"gives up after max retries", []int{500}, 2, 500, 3},
+		{"does not retry 404", []int{404, 200}, 3, 404, 1},
+		{"single attempt without retries", []int{503, 200}, 0, 503, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := statusServer(t, tt.statuses...)
+			fetcher := newTestFetcher(t, tt.maxRetries)
+
+			resp, err := fetch(t, fetcher, http.MethodPost, server.URL, "page=2")
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", got, tt.wantRequests)
+			}
+			// Retried requests are sent with their body rewound
+			if string(body) != "page=2" {
+				t.Errorf("body = %q, want the request body echoed", body)
+			}
+		})
+	}
+}
+
+func TestFetcherReleasesHostSlot(t *testing.T) {
+	server, _ := statusServer(t, 200)
+	fetcher := newTestFetcher(t, 1)
+
+	// With one slot per host, each request waits for the previous to be released
+	for i := 0; i < 3; i++ {
+		resp, err := fetch(t, fetcher, http.MethodGet, server.URL, "")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	// Failed requests release the slot too
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := fetch(t, fetcher, http.MethodGet, closed.URL, ""); err == nil {
+			t.Fatalf("request %d to a closed server succeeded", i)
+		}
+	}
+
+	resp, err := fetch(t, fetcher, http.MethodGet, server.URL, "")
+	if err != nil {
+		t.Fatalf("request after failures: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestFetcherWaitsForHostSlot(t *testing.T) {
+	server, _ := statusServer(t, 200)
+	fetcher := newTestFetcher(t, 0)
+
+	held, err := fetch(t, fetcher, http.MethodGet, server.URL, "")
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := fetcher.Do(req); err != context.DeadlineExceeded {
+		t.Errorf("Do while the slot is held = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	held.Body.Close()
+	resp, err := fetch(t, fetcher, http.MethodGet, server.URL, "")
+	if err != nil {
+		t.Fatalf("Do after release: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestFetcherRetryDelay(t *testing.T) {
+	fetcher, err := NewFetcher(FetcherConfig{RetryBaseDelay: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewFetcher: %v", err)
+	}
+
+	retryAfter := &http.Response{Header: http.Header{"Retry-After": {"7"}}}
+	if got := fetcher.retryDelay(0, retryAfter); got != 7*time.Second {
+		t.Errorf("delay with Retry-After = %v, want 7s", got)
+	}
+
+	// Dates and garbage fall back to backoff, which doubles with each attempt
+	for _, header := range []string{"", "Wed, 21 Oct 2026 07:28:00 GMT", "soon"} {
+		resp := &http.Response{Header: http.Header{"Retry-After": {header}}}
+		for attempt, base := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+			got := fetcher.retryDelay(attempt, resp)
+			if got < base || got > base+base/2 {
+				t.Errorf("Retry-After %q, attempt %d: delay = %v, want between %v and %v", header, attempt, got, base, base+base/2)
+			}
+		}
+	}
+
+	if got := fetcher.retryDelay(1, nil); got < 200*time.Millisecond || got > 300*time.Millisecond {
+		t.Errorf("delay after a network error = %v, want between 200ms and 300ms", got)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/review-aggregator/review-api/app/config"
+)
+
+const (
+	FixturesModeRecord = "record"
+	FixturesModeReplay = "replay"
+)
+
+// NewFixturesTransport wraps next according to config.Config.HTTPFixturesMode
+func NewFixturesTransport(next http.RoundTripper) http.RoundTripper {
+	switch config.Config.HTTPFixturesMode {
+	case FixturesModeRecord:
+		return NewRecordingTransport(next, config.Config.HTTPFixturesDir)
+	case FixturesModeReplay:
+		return NewReplayTransport(config.Config.HTTPFixturesDir)
+	default:
+		return next
+	}
+}
+
+// RecordedExchange is a response stored as a fixture, keyed by its request
+type RecordedExchange struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body"`
+}
+
+// recordingTransport writes every response it sees to dir, so captured
+// traffic can be used as test fixtures. Responses are handed back as they
+// arrive and written once fully read, so streamed responses keep streaming.
+type recordingTransport struct {
+	next http.RoundTripper
+	dir  string
+}
+
+func NewRecordingTransport(next http.RoundTripper, dir string) http.RoundTripper {
+	return &recordingTransport{next: next, dir: dir}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	exchange := RecordedExchange{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(requestBody),
+		StatusCode:  resp.StatusCode,
+		Header:      header,
+	}
+
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		onEOF: func(body []byte) {
+			exchange.Body = string(body)
+			if err := writeFixture(t.dir, req, requestBody, exchange); err != nil {
+				log.Error("Error while recording fixture", err)
+			}
+		},
+	}
+
+	return resp, nil
+}
+
+// recordingBody copies a response body as it is read and calls onEOF with
+// the copy once it has been read to the end. Bodies that fail before the end,
+// e.g. because the request was cancelled, are not recorded.
+type recordingBody struct {
+	io.ReadCloser
+	buffer bytes.Buffer
+	onEOF  func(body []byte)
+}
+
+// Close reads what is left of the body first, since readers such as
+// json.Decoder or the LLM stream parser stop just before the end
+func (b *recordingBody) Close() error {
+	if b.onEOF != nil {
+		io.Copy(io.Discard, b)
+	}
+
+	return b.ReadCloser.Close()
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buffer.Write(p[:n])
+
+	if err == io.EOF && b.onEOF != nil {
+		b.onEOF(b.buffer.Bytes())
+		b.onEOF = nil
+	}
+
+	return n, err
+}
+
+// replayTransport serves responses recorded by recordingTransport and never
+// touches the network, so it fails for any request without a fixture.
+type replayTransport struct {
+	dir string
+}
+
+func NewReplayTransport(dir string) http.RoundTripper {
+	return &replayTransport{dir: dir}
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fixturePath(t.dir, req, requestBody)
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no fixture for %s %s (%s): %w", req.Method, req.URL, path, err)
+	}
+
+	var exchange RecordedExchange
+	if err := json.Unmarshal(fixture, &exchange); err != nil {
+		return nil, fmt.Errorf("error decoding fixture %s: %w", path, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.StatusCode, http.StatusText(exchange.StatusCode)),
+		StatusCode:    exchange.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        exchange.Header,
+		Body:          io.NopCloser(strings.NewReader(exchange.Body)),
+		ContentLength: int64(len(exchange.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads the request body and puts an unread copy back
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// fixturePath names a fixture after the request method, URL and body, so
+// the same request always maps to the same file.
+func fixturePath(dir string, req *http.Request, requestBody []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.String() + "\n"))
+	hash.Write(requestBody)
+
+	return filepath.Join(dir, req.URL.Host, req.Method+"-"+hex.EncodeToString(hash.Sum(nil))[:16]+".json")
+}
+
+func writeFixture(dir string, req *http.Request, requestBody []byte, exchange RecordedExchange) error {
+	path := fixturePath(dir, req, requestBody)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	fixture, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, fixture, 0o644)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecordingTransportStreams(t *testing.T) {
+	const stream = "data: one\n\ndata: two\n\ndata: [DONE]\n\n"
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, stream[:len("data: one\n\n")])
+		w.(http.Flusher).Flush()
+
+		<-release
+		io.WriteString(w, stream[len("data: one\n\n"):])
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := &http.Client{Transport: NewRecordingTransport(http.DefaultTransport, dir)}
+
+	firstLine := make(chan string, 1)
+	var resp *http.Response
+	go func() {
+		var err error
+		resp, err = client.Get(server.URL)
+		if err != nil {
+			firstLine <- "error: " + err.Error()
+			return
+		}
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		firstLine <- line
+	}()
+
+	select {
+	case line := <-firstLine:
+		close(release)
+		if line != "data: one\n" {
+			t.Fatalf("first line = %q, want %q", line, "data: one\n")
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("first event was not passed on before the response finished")
+	}
+
+	// Closed without reading to the end, like the stream parser does after [DONE]
+	resp.Body.Close()
+
+	client = &http.Client{Transport: NewReplayTransport(dir)}
+	replayed, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	defer replayed.Body.Close()
+
+	body, err := io.ReadAll(replayed.Body)
+	if err != nil {
+		t.Fatalf("reading replayed body: %v", err)
+	}
+	if string(body) != stream {
+		t.Errorf("replayed body = %q, want %q", body, stream)
+	}
+}
@@ -0,0 +1,7 @@
+package services
+
+import "github.com/review-aggregator/review-api/app/utils"
+
+var (
+	log = utils.CreateLogger()
+)
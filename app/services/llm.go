@@ -4,25 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/lib/pq"
+	"github.com/review-aggregator/review-api/app/config"
 	"github.com/review-aggregator/review-api/app/consts"
 	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/prompts"
 )
 
 const (
-	model               = "deepseek-r1"
-	groqModel           = "llama-3.1-8b-instant"
-	ReviewTypeSummary   = "summary"
-	ReviewTypeSentiment = "sentiment"
-	openAPIURL          = "http://localhost:11434/api/chat"
-	groqAPIURL          = "https://api.groq.com/openai/v1/chat/completions"
+	model      = "deepseek-r1"
+	groqModel  = "llama-3.1-8b-instant"
+	openAPIURL = "http://localhost:11434/api/chat"
+	groqAPIURL = "https://api.groq.com/openai/v1/chat/completions"
 )
 
 type LLMProvider string
@@ -32,6 +33,70 @@ const (
 	ProviderGroq   LLMProvider = "groq"
 )
 
+// ErrInvalidLLMOutput is wrapped by errors for model output that does not
+// match the JSON structure the prompt asked for
+var ErrInvalidLLMOutput = errors.New("invalid LLM output")
+
+// ErrNoReviews is returned instead of asking the LLM about an empty list of
+// reviews, e.g. when every review was excluded as suspicious
+var ErrNoReviews = errors.New("no reviews to analyze")
+
+// ConfiguredLLMProvider returns config.Config.LLMProvider, defaulting to Groq
+func ConfiguredLLMProvider() LLMProvider {
+	if config.Config.LLMProvider == "" {
+		return ProviderGroq
+	}
+
+	return LLMProvider(config.Config.LLMProvider)
+}
+
+// LLMModel returns the model used with provider, config.Config.LLMModel if set
+func LLMModel(provider LLMProvider) string {
+	if config.Config.LLMModel != "" {
+		return config.Config.LLMModel
+	}
+
+	switch provider {
+	case ProviderOllama:
+		return model
+	default:
+		return groqModel
+	}
+}
+
+// llmResponseTimeout bounds how long the provider may take to answer. A
+// streamed response keeps going after that for as long as its context allows.
+const llmResponseTimeout = 2 * time.Minute
+
+var (
+	llmClient     *http.Client
+	llmClientOnce sync.Once
+)
+
+// LLMClient returns the HTTP client used for LLM calls, recording or
+// replaying fixtures when config.Config.HTTPFixturesMode is set. It has no
+// overall timeout, which would cut off long streamed responses; callLLMAPI
+// bounds unstreamed calls through their context instead.
+func LLMClient() *http.Client {
+	llmClientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = llmResponseTimeout
+
+		llmClient = &http.Client{
+			Transport: NewFixturesTransport(transport),
+		}
+	})
+
+	return llmClient
+}
+
+// SetLLMClient replaces the HTTP client used for LLM calls, e.g. with one
+// replaying fixtures in tests
+func SetLLMClient(client *http.Client) {
+	llmClientOnce.Do(func() {})
+	llmClient = client
+}
+
 type PlatformNameWithID struct {
 	PlatformName consts.PlatformType
 	PlatformID   uuid.UUID
@@ -39,6 +104,8 @@ type PlatformNameWithID struct {
 
 func GenerateProductStats(ctx context.Context, productID uuid.UUID, userID uuid.UUID) error {
 	fmt.Println("started generating product stats")
+	ctx = WithLLMAttribution(ctx, productID, userID)
+
 	product, err := models.GetProductByID(ctx, productID)
 	if err != nil {
 		return fmt.Errorf("error getting product: %w", err)
@@ -49,6 +116,17 @@ func GenerateProductStats(ctx context.Context, productID uuid.UUID, userID uuid.
 		return fmt.Errorf("error getting platforms: %w", err)
 	}
 
+	// Anomalies do not depend on the LLM, so they are kept up to date even
+	// when generating the stats fails
+	if err := DetectReviewAnomalies(ctx, productID); err != nil {
+		log.Error("Error while detecting review anomalies", err)
+	}
+
+	// Scored first so summaries can leave suspicious reviews out
+	if err := ScoreReviewSuspicion(ctx, product); err != nil {
+		log.Error("Error while scoring review suspicion", err)
+	}
+
 	PrettyPrint(platforms)
 
 	// No platforms have been added for this product
@@ -56,13 +134,18 @@ func GenerateProductStats(ctx context.Context, productID uuid.UUID, userID uuid.
 		return fmt.Errorf("no platforms found")
 	}
 
+	categories, err := SentimentCategoriesForProduct(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("error getting sentiment categories: %w", err)
+	}
+
 	// Only one platform has been added for this product so we can store the result with platform as "all" in product_stats table
 	platformTypes := []PlatformNameWithID{}
 	if len(platforms) == 1 {
 		fmt.Println("Single platform found for product ID:", productID)
 		for _, timePeriod := range consts.TimePeriods {
 			fmt.Println("Started for time period: ", timePeriod)
-			err := processTimePeriodsStats(ctx, productID, userID, product.Description, timePeriod)
+			err := processTimePeriodsStats(ctx, productID, userID, product.Description, categories, timePeriod)
 			if err != nil {
 				return fmt.Errorf("error processing time period %s: %w", timePeriod, err)
 			}
@@ -91,25 +174,24 @@ func GenerateProductStats(ctx context.Context, productID uuid.UUID, userID uuid.
 				}
 
 				productStats, err := GetProductStats(ctx, reviews, product.Description)
-				if err != nil {
-					return fmt.Errorf("error getting product stats: %w", err)
+				if errors.Is(err, ErrNoReviews) {
+					log.Info("No reviews to generate stats from for platform ", platform.PlatformName, " and time period ", timePeriod)
+					continue
 				}
-
-				productSentiment, err := GetSentimentAnalysis(ctx, reviews, product.Description)
 				if err != nil {
 					return fmt.Errorf("error getting product stats: %w", err)
 				}
 
-				var productSentimentPQArray pq.StringArray
-				err = json.Unmarshal([]byte(productSentiment), &productSentimentPQArray)
+				productSentiment, sentimentPromptVersion, err := GetSentimentAnalysis(ctx, reviews, product.Description, categories)
 				if err != nil {
-					return fmt.Errorf("error unmarshalling sentiment count: %w", err)
+					return fmt.Errorf("error getting product stats: %w", err)
 				}
 
 				productStats.ProductID = productID
 				productStats.Platform = platform.PlatformName
 				productStats.TimePeriod = timePeriod
-				productStats.SentimentCount = productSentimentPQArray
+				productStats.SentimentCount = productSentiment
+				productStats.SentimentPromptVersion = &sentimentPromptVersion
 
 				err = models.CreateProductStats(ctx, productStats)
 				if err != nil {
@@ -122,14 +204,18 @@ func GenerateProductStats(ctx context.Context, productID uuid.UUID, userID uuid.
 	return nil
 }
 
-func processTimePeriodsStats(ctx context.Context, productID uuid.UUID, userID uuid.UUID, productDescription string, timePeriod consts.TimePeriodType) error {
+func processTimePeriodsStats(ctx context.Context, productID uuid.UUID, userID uuid.UUID, productDescription string, categories []*models.SentimentCategory, timePeriod consts.TimePeriodType) error {
 	fmt.Println("processing time periods stats for product ID:", productID, "and time period:", timePeriod)
 	reviews, err := models.GetReviewsByProductIDAndUserIDAndTimePeriod(ctx, productID, userID, timePeriod)
 	if err != nil {
 		return fmt.Errorf("error getting reviews: %w", err)
 	}
 
-	productSentiment, err := GetSentimentAnalysis(ctx, reviews, productDescription)
+	productSentiment, sentimentPromptVersion, err := GetSentimentAnalysis(ctx, reviews, productDescription, categories)
+	if errors.Is(err, ErrNoReviews) {
+		log.Info("No reviews to generate stats from for time period ", timePeriod)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("error getting sentiment analysis: %w", err)
 	}
@@ -137,12 +223,6 @@ func processTimePeriodsStats(ctx context.Context, productID uuid.UUID, userID uu
 	fmt.Println("product sentiment:")
 	PrettyPrint(productSentiment)
 
-	var productSentimentPQArray pq.StringArray
-	err = json.Unmarshal([]byte(productSentiment), &productSentimentPQArray)
-	if err != nil {
-		return fmt.Errorf("error unmarshalling sentiment count: %w", err)
-	}
-
 	fmt.Println("sleeping for 30 seconds")
 	time.Sleep(60 * time.Second)
 	fmt.Println("starting product stats")
@@ -157,7 +237,8 @@ func processTimePeriodsStats(ctx context.Context, productID uuid.UUID, userID uu
 	productStats.ProductID = productID
 	productStats.Platform = consts.PlatformAll
 	productStats.TimePeriod = timePeriod
-	productStats.SentimentCount = productSentimentPQArray
+	productStats.SentimentCount = productSentiment
+	productStats.SentimentPromptVersion = &sentimentPromptVersion
 
 	err = models.CreateProductStats(ctx, productStats)
 	if err != nil {
@@ -168,33 +249,74 @@ func processTimePeriodsStats(ctx context.Context, productID uuid.UUID, userID uu
 	return nil
 }
 
-func callLLMAPI(ctx context.Context, messages []map[string]string, provider LLMProvider, apiKey string) (string, error) {
+// callLLMAPI sends messages to the provider and records the call's token
+// usage, latency and cost under operation
+func callLLMAPI(ctx context.Context, messages []map[string]string, provider LLMProvider, apiKey string, operation string) (string, error) {
+	requestCtx, cancel := context.WithTimeout(ctx, llmResponseTimeout)
+	defer cancel()
+
+	req, llmModel, err := newLLMRequest(requestCtx, messages, provider, apiKey, false)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	response, usage, err := sendLLMRequest(req, nil)
+	recordLLMUsage(ctx, provider, llmModel, operation, usage, time.Since(start), err == nil)
+
+	return response, err
+}
+
+// streamLLMAPI is callLLMAPI with the response streamed, onChunk is called
+// with each piece of content as the model generates it. The stream runs until
+// the model is done or ctx is cancelled, e.g. by the client disconnecting.
+func streamLLMAPI(ctx context.Context, messages []map[string]string, provider LLMProvider, apiKey string, operation string, onChunk func(string)) (string, error) {
+	req, llmModel, err := newLLMRequest(ctx, messages, provider, apiKey, true)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	response, usage, err := sendLLMRequest(req, onChunk)
+	recordLLMUsage(ctx, provider, llmModel, operation, usage, time.Since(start), err == nil)
+
+	return response, err
+}
+
+// newLLMRequest builds the chat request for the provider and returns it with
+// the model it asks for
+func newLLMRequest(ctx context.Context, messages []map[string]string, provider LLMProvider, apiKey string, stream bool) (*http.Request, string, error) {
 	requestBody := map[string]interface{}{
 		"messages": messages,
-		"stream":   false,
+		"stream":   stream,
 	}
 
+	llmModel := LLMModel(provider)
+	requestBody["model"] = llmModel
+
 	switch provider {
 	case ProviderGroq:
-		requestBody["model"] = groqModel
 		requestBody["temperature"] = 1
 		requestBody["max_completion_tokens"] = 1024
 		requestBody["top_p"] = 1
 		requestBody["stop"] = nil
+		if stream {
+			// Token usage is only sent in the final event when asked for
+			requestBody["stream_options"] = map[string]bool{"include_usage": true}
+		}
 	case ProviderOllama:
-		requestBody["model"] = model
 	default:
-		return "", fmt.Errorf("unsupported LLM provider: %s", provider)
+		return nil, "", fmt.Errorf("unsupported LLM provider: %s", provider)
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %w", err)
+		return nil, "", fmt.Errorf("error marshaling request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", getAPIURL(provider), bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return nil, "", fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -202,28 +324,42 @@ func callLLMAPI(ctx context.Context, messages []map[string]string, provider LLMP
 		req.Header.Set("Authorization", "Bearer "+apiKey)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return req, llmModel, nil
+}
+
+// sendLLMRequest sends req and returns the complete response. Streamed
+// responses are read as they arrive and passed to onChunk, which may be nil.
+func sendLLMRequest(req *http.Request, onChunk func(string)) (string, TokenUsage, error) {
+	resp, err := LLMClient().Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error calling API: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("error calling API: %w", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return "", fmt.Errorf("API returned non-200 status code: %d", resp.StatusCode)
+		return "", TokenUsage{}, fmt.Errorf("API returned non-200 status code: %d", resp.StatusCode)
+	}
+
+	if onChunk != nil {
+		response, usage, err := readStreamingResponse(resp.Body, onChunk)
+		if err != nil {
+			return "", usage, fmt.Errorf("error reading streaming response: %w", err)
+		}
+
+		return response, usage, nil
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("error reading response body: %w", err)
 	}
 
-	response, err := readStreamingResponse(body)
+	response, usage, err := readLLMResponse(body)
 	if err != nil {
-		return "", fmt.Errorf("error reading streaming response: %w", err)
+		return "", usage, fmt.Errorf("error reading response: %w", err)
 	}
 
-	return response, nil
+	return response, usage, nil
 }
 
 func getAPIURL(provider LLMProvider) string {
@@ -237,37 +373,72 @@ func getAPIURL(provider LLMProvider) string {
 	}
 }
 
+// maxSummaryItems limits the key highlights and pain points in a summary
+const maxSummaryItems = 5
+
+// DefaultSentimentCategories are the topics the sentiment analysis counts
+// opinions on for products without categories of their own
+var DefaultSentimentCategories = []string{"Product Quality", "User Experience", "Price Value", "Customer Service"}
+
+// SentimentCategoriesForProduct returns the product's sentiment categories,
+// or DefaultSentimentCategories if it has not defined any
+func SentimentCategoriesForProduct(ctx context.Context, productID uuid.UUID) ([]*models.SentimentCategory, error) {
+	categories, err := models.GetSentimentCategoriesByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(categories) == 0 {
+		for position, name := range DefaultSentimentCategories {
+			categories = append(categories, &models.SentimentCategory{
+				ProductID:      productID,
+				Name:           name,
+				ExamplePhrases: []string{},
+				Position:       position,
+			})
+		}
+	}
+
+	return categories, nil
+}
+
 func GetProductStats(ctx context.Context, reviews []*models.Review, productDescription string) (*models.ProductStats, error) {
-	messages := []map[string]string{
-		{
-			"role": "system",
-			"content": `You are a review analyzer. Your task is to analyze and summarize product reviews and provide key highlights and pain points strictly in JSON format.
-			Ensure that your response is **only** a valid JSON object and nothing else—no explanations, no introductions, no formatting hints, and no <think> tags.
-			Here is the required JSON structure:
-	
-			{
-				"key_highlights": ["highlight1", "highlight2", ...],
-				"pain_points": ["issue1", "issue2", ...],
-				"overall_sentiment": "brief summary of customer satisfaction"
-			}
+	return summarizeReviews(ctx, reviews, productDescription, nil)
+}
+
+// StreamProductStats is GetProductStats with the model's output passed to
+// onChunk as it is generated
+func StreamProductStats(ctx context.Context, reviews []*models.Review, productDescription string, onChunk func(string)) (*models.ProductStats, error) {
+	return summarizeReviews(ctx, reviews, productDescription, onChunk)
+}
+
+// summarizeReviews streams the response when onChunk is set
+func summarizeReviews(ctx context.Context, reviews []*models.Review, productDescription string, onChunk func(string)) (*models.ProductStats, error) {
+	reviews = ExcludeSuspiciousReviews(reviews)
+	if len(reviews) == 0 {
+		return nil, ErrNoReviews
+	}
 
-			"key_highlights" and "pain_points" should give an array output. Limit the number of key highlights and pain points to 5.
-			"overall_sentiment" should be a string which is a brief summary of all reviews"
-	
-			DO NOT ADD OR USE ANY CURLY BRACKETS i.e. { or } IN THE <think> TAGS.
-			Do not include any additional text before or after the JSON object.
-			Do not add these fields within another object, field or array.
-			Strictly follow the JSON structure and do not add any additional fields or properties.
-			Ensure the fields used are "key_highlights", "pain_points" and "overall_sentiment" and if you are unable to find any, return an empty array.`,
-		},
-		{
-			"role":    "user",
-			"content": FormatReviewsForPrompt(reviews, ReviewTypeSummary, productDescription),
-		},
-	}
-
-	// Use Ollama by default, can be changed to ProviderGroq
-	body, err := callLLMAPI(ctx, messages, ProviderGroq, os.Getenv("GROQ_API_KEY_SUMMARY"))
+	prompt, err := prompts.Get(prompts.Summary)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := prompt.Messages(prompts.SummaryData{
+		ProductDescription: productDescription,
+		Reviews:            promptReviews(reviews),
+		MaxItems:           maxSummaryItems,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var body string
+	if onChunk != nil {
+		body, err = streamLLMAPI(ctx, messages, ConfiguredLLMProvider(), os.Getenv("GROQ_API_KEY_SUMMARY"), LLMOperationSummary, onChunk)
+	} else {
+		body, err = callLLMAPI(ctx, messages, ConfiguredLLMProvider(), os.Getenv("GROQ_API_KEY_SUMMARY"), LLMOperationSummary)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error calling LLM API: %w", err)
 	}
@@ -275,148 +446,126 @@ func GetProductStats(ctx context.Context, reviews []*models.Review, productDescr
 	productStats := &models.ProductStats{}
 	err = json.Unmarshal([]byte(body), productStats)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+		return nil, fmt.Errorf("%w: error unmarshalling response: %w", ErrInvalidLLMOutput, err)
 	}
 
+	promptVersion := prompt.ID()
+	productStats.SummaryPromptVersion = &promptVersion
+
 	return productStats, nil
 }
 
-type SentimentCategory struct {
-	Category  string `json:"category"`
-	Positive  int    `json:"positive"`
-	Negative  int    `json:"negative"`
-	NoOpinion int    `json:"no_opinion"`
-}
+// GetSentimentAnalysis returns the sentiment counts per category, along with
+// the ID of the prompt that produced them
+func GetSentimentAnalysis(ctx context.Context, reviews []*models.Review, productDescription string, categories []*models.SentimentCategory) (models.SentimentCounts, string, error) {
+	reviews = ExcludeSuspiciousReviews(reviews)
+	if len(reviews) == 0 {
+		return nil, "", ErrNoReviews
+	}
 
-func GetSentimentAnalysis(ctx context.Context, reviews []*models.Review, productDescription string) (string, error) {
-	categories := []string{"Product Quality", "User Experience", "Price Value", "Customer Service"}
-	messages := []map[string]string{
-		{
-			"role": "system",
-			"content": fmt.Sprintf(`You are a sentiment analyzer. Your task is to analyze and count which reviews are positive, negative or have no opinion about "%s".
-			Ensure that your response is **only** a valid JSON object and nothing else—no explanations, no introductions, no formatting hints, and no <think> tags.
-			Here is the required JSON structure:
-	
-			[{
-				"category": "%s",
-				"positive": 0,
-				"negative": 0,
-				"no_opinion": 0
-			},
-			{
-				"category": "%s",
-				"positive": 0,
-				"negative": 0,
-				"no_opinion": 0
-			},
-			{
-				"category": "%s",
-				"positive": 0,
-				"negative": 0,
-				"no_opinion": 0
-			},
-			{
-				"category": "%s",
-				"positive": 0,
-				"negative": 0,
-				"no_opinion": 0
-			}]
-	
-			DO NOT ADD OR USE ANY CURLY BRACKETS i.e. { or } IN THE <think> TAGS.
-			Do not include any additional text before or after the JSON object.
-			Do not add these fields within another object, field or array.
-			Strictly follow the JSON structure and do not add any additional fields or properties.
-			If a review doesn't mention anything about %s, count it as "no_opinion".`, categories[0], categories[0], categories[1], categories[2], categories[3], categories[0]),
-		},
-		{
-			"role":    "user",
-			"content": FormatReviewsForPrompt(reviews, ReviewTypeSentiment, productDescription),
-		},
-	}
-
-	// Use Ollama by default, can be changed to ProviderGroq
-	body, err := callLLMAPI(ctx, messages, ProviderGroq, os.Getenv("GROQ_API_KEY_SENTIMENT"))
+	prompt, err := prompts.Get(prompts.Sentiment)
 	if err != nil {
-		return "", fmt.Errorf("error calling LLM API: %w", err)
+		return nil, "", err
 	}
 
-	// Parse the response into our struct
-	var sentimentCategories []SentimentCategory
-	if err := json.Unmarshal([]byte(body), &sentimentCategories); err != nil {
-		return "", fmt.Errorf("error unmarshalling sentiment categories: %w", err)
+	categoryNames := make([]string, 0, len(categories))
+	categoryDetails := make([]prompts.CategoryData, 0, len(categories))
+	for _, category := range categories {
+		categoryNames = append(categoryNames, category.Name)
+		categoryDetails = append(categoryDetails, prompts.CategoryData{
+			Name:           category.Name,
+			Description:    category.Description,
+			ExamplePhrases: category.ExamplePhrases,
+		})
 	}
 
-	// Convert to string array format for PostgreSQL
-	var sentimentStrings []string
-	for _, category := range sentimentCategories {
-		categoryJSON, err := json.Marshal(category)
-		if err != nil {
-			return "", fmt.Errorf("error marshalling category: %w", err)
-		}
-		sentimentStrings = append(sentimentStrings, string(categoryJSON))
+	messages, err := prompt.Messages(prompts.SentimentData{
+		ProductDescription: productDescription,
+		Reviews:            promptReviews(reviews),
+		Categories:         categoryNames,
+		CategoryDetails:    categoryDetails,
+	})
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Convert back to JSON string array
-	resultJSON, err := json.Marshal(sentimentStrings)
+	body, err := callLLMAPI(ctx, messages, ConfiguredLLMProvider(), os.Getenv("GROQ_API_KEY_SENTIMENT"), LLMOperationSentiment)
 	if err != nil {
-		return "", fmt.Errorf("error marshalling final result: %w", err)
+		return nil, "", fmt.Errorf("error calling LLM API: %w", err)
 	}
 
-	return string(resultJSON), nil
+	var sentimentCounts models.SentimentCounts
+	if err := json.Unmarshal([]byte(body), &sentimentCounts); err != nil {
+		return nil, "", fmt.Errorf("%w: error unmarshalling sentiment categories: %w", ErrInvalidLLMOutput, err)
+	}
+
+	return sentimentCounts, prompt.ID(), nil
 }
 
-// readStreamingResponse reads a non-streaming response and returns the complete response
-func readStreamingResponse(body []byte) (string, error) {
-	var result map[string]interface{}
+type llmMessage struct {
+	Content string `json:"content"`
+}
+
+// llmResponse covers both OpenAI compatible responses (Groq), which carry
+// choices and usage, and Ollama responses, which carry message and eval counts
+type llmResponse struct {
+	Choices []struct {
+		Message llmMessage `json:"message"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Message         *llmMessage `json:"message"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+// readLLMResponse reads a non-streaming response and returns the content
+// with the token usage reported by the API
+func readLLMResponse(body []byte) (string, TokenUsage, error) {
+	var result llmResponse
 	decoder := json.NewDecoder(bytes.NewReader(body))
 	if err := decoder.Decode(&result); err != nil {
-		return "", fmt.Errorf("error decoding response: %w", err)
+		return "", TokenUsage{}, fmt.Errorf("error decoding response: %w", err)
 	}
 
 	var fullResponse string
-	if choices, ok := result["choices"].([]interface{}); ok && len(choices) > 0 {
-		if choice, ok := choices[0].(map[string]interface{}); ok {
-			if message, ok := choice["message"].(map[string]interface{}); ok {
-				if content, ok := message["content"].(string); ok {
-					fullResponse = content
-				}
-			}
-		}
+	var usage TokenUsage
+	if len(result.Choices) > 0 {
+		fullResponse = result.Choices[0].Message.Content
+	} else if result.Message != nil {
+		fullResponse = result.Message.Content
+	}
+
+	if result.Usage != nil {
+		usage = TokenUsage{PromptTokens: result.Usage.PromptTokens, CompletionTokens: result.Usage.CompletionTokens}
+	} else {
+		usage = TokenUsage{PromptTokens: result.PromptEvalCount, CompletionTokens: result.EvalCount}
 	}
 
 	if fullResponse == "" {
-		return "", fmt.Errorf("no valid response content from API")
+		return "", usage, fmt.Errorf("no valid response content from API")
 	}
 
 	// Log the raw response for debugging
 	fmt.Println("Raw response from model:", fullResponse)
 
-	return fullResponse, nil
-}
-
-type ReviewData struct {
-	RatingValue float64
-	ReviewBody  string
+	return fullResponse, usage, nil
 }
 
-// formatReviewsForPrompt converts the reviews into a string format suitable for the prompt
-func FormatReviewsForPrompt(reviews []*models.Review, reviewType string, productDescription string) string {
-	var reviewTexts []ReviewData
+// promptReviews converts the reviews into the form the prompt templates render
+func promptReviews(reviews []*models.Review) []prompts.ReviewData {
+	reviewData := make([]prompts.ReviewData, 0, len(reviews))
 	for _, review := range reviews {
-		reviewTexts = append(reviewTexts, ReviewData{
+		reviewData = append(reviewData, prompts.ReviewData{
 			RatingValue: review.RatingValue,
+			Headline:    review.Headline,
 			ReviewBody:  review.ReviewBody,
 		})
 	}
 
-	prompt := ""
-	if reviewType == ReviewTypeSummary {
-		prompt = fmt.Sprintf("You are a review analyzer. Your task is to analyze and summarize product reviews and provide key highlights and pain points strictly in JSON format.\n\nProduct Description: %s\n\n", productDescription)
-	} else if reviewType == ReviewTypeSentiment {
-		prompt = fmt.Sprintf("You are a sentiment analyzer. Your task is to analyze and count which reviews are positive, negative or neutral on the following topics 'Product Quality', 'User Experience', 'Price Value' and 'Customer Service'.\n\nProduct Description: %s\n\n", productDescription)
-	}
-
-	return prompt + fmt.Sprintf("%v", reviewTexts)
+	return reviewData
 }
 
 // PrettyPrint prints any struct in a readable JSON format.
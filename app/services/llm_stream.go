@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const sseDataPrefix = "data:"
+
+// llmStreamChunk covers the events of OpenAI compatible SSE streams (Groq),
+// which carry choice deltas and usage in the last event, and the lines of
+// Ollama NDJSON streams, which carry message pieces and eval counts when done
+type llmStreamChunk struct {
+	Choices []struct {
+		Delta llmMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	// Groq also reports usage under x_groq in the last event
+	XGroq *struct {
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	} `json:"x_groq"`
+	Message         *llmMessage `json:"message"`
+	Done            bool        `json:"done"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           interface{} `json:"error"`
+}
+
+// readStreamingResponse reads an SSE or NDJSON stream, passing each piece of
+// content to onChunk as it arrives, and returns the complete response with the
+// token usage reported by the API
+func readStreamingResponse(body io.Reader, onChunk func(string)) (string, TokenUsage, error) {
+	var fullResponse strings.Builder
+	var usage TokenUsage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// SSE events are "data: <json>" lines, other SSE fields and comments
+		// carry nothing we need. NDJSON lines are the JSON itself.
+		payload := line
+		if strings.HasPrefix(line, sseDataPrefix) {
+			payload = strings.TrimSpace(strings.TrimPrefix(line, sseDataPrefix))
+		} else if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		if payload == "[DONE]" {
+			break
+		}
+
+		var chunk llmStreamChunk
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			return "", usage, fmt.Errorf("error decoding stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return "", usage, fmt.Errorf("API returned an error: %v", chunk.Error)
+		}
+
+		var content string
+		if len(chunk.Choices) > 0 {
+			content = chunk.Choices[0].Delta.Content
+		} else if chunk.Message != nil {
+			content = chunk.Message.Content
+		}
+
+		if content != "" {
+			fullResponse.WriteString(content)
+			onChunk(content)
+		}
+
+		switch {
+		case chunk.Usage != nil:
+			usage = TokenUsage{PromptTokens: chunk.Usage.PromptTokens, CompletionTokens: chunk.Usage.CompletionTokens}
+		case chunk.XGroq != nil && chunk.XGroq.Usage != nil:
+			usage = TokenUsage{PromptTokens: chunk.XGroq.Usage.PromptTokens, CompletionTokens: chunk.XGroq.Usage.CompletionTokens}
+		case chunk.Done:
+			usage = TokenUsage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+		}
+
+		if chunk.Done {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", usage, fmt.Errorf("error reading stream: %w", err)
+	}
+
+	if fullResponse.Len() == 0 {
+		return "", usage, fmt.Errorf("no valid response content from API")
+	}
+
+	return fullResponse.String(), usage, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+// replayLLM serves LLM calls from the fixtures and collects the usage they record
+func replayLLM(t *testing.T) *[]*models.LLMUsage {
+	t.Helper()
+
+	SetLLMClient(&http.Client{Transport: NewReplayTransport(fixturesDir)})
+
+	var recorded []*models.LLMUsage
+	SetLLMUsageRecorder(func(ctx context.Context, usage *models.LLMUsage) error {
+		recorded = append(recorded, usage)
+		return nil
+	})
+	t.Cleanup(func() { SetLLMUsageRecorder(models.CreateLLMUsage) })
+
+	return &recorded
+}
+
+func TestCallLLMAPI(t *testing.T) {
+	recorded := replayLLM(t)
+
+	productID := uuid.New()
+	ctx := WithLLMAttribution(context.Background(), productID, uuid.Nil)
+	messages := []map[string]string{
+		{"role": "system", "content": "Summarize the reviews as JSON."},
+		{"role": "user", "content": "- Rating: 5.0 | Spotless rooms\n- Rating: 2.0 | Slow check-in\n"},
+	}
+
+	response, err := callLLMAPI(ctx, messages, ProviderGroq, "test", LLMOperationSummary)
+	if err != nil {
+		t.Fatalf("callLLMAPI: %v", err)
+	}
+
+	want := `{"key_highlights": ["Spotless sea-facing rooms"], "pain_points": ["Slow check-in"]}`
+	if response != want {
+		t.Errorf("response = %q, want %q", response, want)
+	}
+
+	if len(*recorded) != 1 {
+		t.Fatalf("recorded %d usages, want 1", len(*recorded))
+	}
+	usage := (*recorded)[0]
+	if usage.PromptTokens != 182 || usage.CompletionTokens != 27 {
+		t.Errorf("got %d prompt and %d completion tokens, want 182 and 27", usage.PromptTokens, usage.CompletionTokens)
+	}
+	if usage.Operation != LLMOperationSummary || usage.Model != groqModel || !usage.Succeeded {
+		t.Errorf("got operation %q, model %q, succeeded %v", usage.Operation, usage.Model, usage.Succeeded)
+	}
+	if usage.ProductID == nil || *usage.ProductID != productID {
+		t.Errorf("usage is not attributed to the product")
+	}
+}
+
+func TestCallLLMAPIWithoutFixture(t *testing.T) {
+	recorded := replayLLM(t)
+
+	messages := []map[string]string{{"role": "user", "content": "A prompt that was never recorded"}}
+	if _, err := callLLMAPI(context.Background(), messages, ProviderGroq, "test", LLMOperationSummary); err == nil {
+		t.Fatal("callLLMAPI succeeded without a fixture")
+	}
+
+	if len(*recorded) != 1 || (*recorded)[0].Succeeded {
+		t.Errorf("the failed call was not recorded as failed")
+	}
+}
+
+func TestAnalysisWithoutReviewsSkipsLLM(t *testing.T) {
+	recorded := replayLLM(t)
+
+	previous := config.Config
+	config.Config.ExcludeSuspiciousReviews = true
+	config.Config.SuspicionThreshold = 0.7
+	t.Cleanup(func() { config.Config = previous })
+
+	suspicion := 0.9
+	reviews := []*models.Review{{ID: uuid.New(), RatingValue: 5, ReviewBody: "Great product!", SuspicionScore: &suspicion}}
+
+	if _, err := GetProductStats(context.Background(), reviews, "A laptop"); !errors.Is(err, ErrNoReviews) {
+		t.Errorf("GetProductStats of suspicious reviews: got %v, want ErrNoReviews", err)
+	}
+	if _, _, err := GetSentimentAnalysis(context.Background(), nil, "A laptop", nil); !errors.Is(err, ErrNoReviews) {
+		t.Errorf("GetSentimentAnalysis of no reviews: got %v, want ErrNoReviews", err)
+	}
+
+	if len(*recorded) != 0 {
+		t.Errorf("the LLM was called %d times", len(*recorded))
+	}
+}
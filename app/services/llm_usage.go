@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+// Operations recorded on LLM usage
+const (
+	LLMOperationSummary   = "summary"
+	LLMOperationSentiment = "sentiment"
+	LLMOperationReply     = "reply"
+	LLMOperationAnswer    = "answer"
+	LLMOperationCompare   = "compare"
+	LLMOperationSuspicion = "suspicion"
+)
+
+// ModelPricing is a model's price in USD per million tokens
+type ModelPricing struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// modelPricing lists the models we pay for. Models missing here, such as
+// local Ollama models, are recorded at no cost.
+var modelPricing = map[string]ModelPricing{
+	"llama-3.1-8b-instant":    {PromptPerMillion: 0.05, CompletionPerMillion: 0.08},
+	"llama-3.3-70b-versatile": {PromptPerMillion: 0.59, CompletionPerMillion: 0.79},
+}
+
+// EstimateLLMCost returns the cost in USD of a call to model
+func EstimateLLMCost(model string, promptTokens, completionTokens int) float64 {
+	pricing := modelPricing[model]
+	return (float64(promptTokens)*pricing.PromptPerMillion + float64(completionTokens)*pricing.CompletionPerMillion) / 1e6
+}
+
+// TokenUsage is the token count reported by the LLM API for a call
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+type llmAttributionKey struct{}
+
+type llmAttribution struct {
+	productID *uuid.UUID
+	userID    *uuid.UUID
+}
+
+// WithLLMAttribution attributes the LLM calls made with ctx to a product and,
+// when userID is not uuid.Nil, to the user who triggered them
+func WithLLMAttribution(ctx context.Context, productID, userID uuid.UUID) context.Context {
+	attribution := llmAttribution{}
+	if productID != uuid.Nil {
+		attribution.productID = &productID
+	}
+	if userID != uuid.Nil {
+		attribution.userID = &userID
+	}
+
+	return context.WithValue(ctx, llmAttributionKey{}, attribution)
+}
+
+var llmUsageRecorder = models.CreateLLMUsage
+
+// SetLLMUsageRecorder replaces how LLM usage is stored, e.g. to skip the
+// database in tools that run the pipelines offline
+func SetLLMUsageRecorder(recorder func(ctx context.Context, usage *models.LLMUsage) error) {
+	llmUsageRecorder = recorder
+}
+
+// recordLLMUsage stores a call's usage. Failures are logged and otherwise
+// ignored so accounting never breaks the call itself.
+func recordLLMUsage(ctx context.Context, provider LLMProvider, model, operation string, usage TokenUsage, latency time.Duration, succeeded bool) {
+	attribution, _ := ctx.Value(llmAttributionKey{}).(llmAttribution)
+
+	record := models.LLMUsage{
+		Provider:         string(provider),
+		Model:            model,
+		Operation:        operation,
+		ProductID:        attribution.productID,
+		UserID:           attribution.userID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		LatencyMs:        latency.Milliseconds(),
+		CostUSD:          EstimateLLMCost(model, usage.PromptTokens, usage.CompletionTokens),
+		Succeeded:        succeeded,
+	}
+
+	if err := llmUsageRecorder(context.WithoutCancel(ctx), &record); err != nil {
+		log.Error("Error while recording llm usage", err)
+	}
+}
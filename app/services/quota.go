@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+// Unlimited disables a plan limit
+const Unlimited = -1
+
+type Plan struct {
+	Name                      consts.PlanType `json:"name"`
+	MaxProducts               int             `json:"max_products"`
+	MaxPlatformsPerProduct    int             `json:"max_platforms_per_product"`
+	MaxStatsGenerationsPerDay int             `json:"max_stats_generations_per_day"`
+	MaxReviewsStored          int             `json:"max_reviews_stored"`
+}
+
+var Plans = map[consts.PlanType]Plan{
+	consts.PlanFree: {
+		Name:                      consts.PlanFree,
+		MaxProducts:               3,
+		MaxPlatformsPerProduct:    2,
+		MaxStatsGenerationsPerDay: 5,
+		MaxReviewsStored:          5000,
+	},
+	consts.PlanPro: {
+		Name:                      consts.PlanPro,
+		MaxProducts:               25,
+		MaxPlatformsPerProduct:    5,
+		MaxStatsGenerationsPerDay: 50,
+		MaxReviewsStored:          100000,
+	},
+	consts.PlanEnterprise: {
+		Name:                      consts.PlanEnterprise,
+		MaxProducts:               Unlimited,
+		MaxPlatformsPerProduct:    Unlimited,
+		MaxStatsGenerationsPerDay: Unlimited,
+		MaxReviewsStored:          Unlimited,
+	},
+}
+
+// PlanFor returns the user's plan, falling back to the free plan
+func PlanFor(user *models.User) Plan {
+	if plan, ok := Plans[user.Plan]; ok {
+		return plan
+	}
+
+	return Plans[consts.PlanFree]
+}
+
+// QuotaError is returned when an action would exceed a plan limit. Status is
+// 402 for limits lifted by upgrading and 429 for limits that reset daily.
+type QuotaError struct {
+	Status     int
+	Resource   string
+	Plan       consts.PlanType
+	Limit      int
+	Used       int
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s limit of %d reached on the %s plan", e.Resource, e.Limit, e.Plan)
+}
+
+func exceeds(limit, used, adding int) bool {
+	return limit != Unlimited && used+adding > limit
+}
+
+// startOfDay is when the daily quotas were last reset. Days are UTC.
+func startOfDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour)
+}
+
+func CheckProductQuota(ctx context.Context, user *models.User) error {
+	plan := PlanFor(user)
+
+	used, err := models.CountProductsByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if exceeds(plan.MaxProducts, used, 1) {
+		return &QuotaError{Status: http.StatusPaymentRequired, Resource: "products", Plan: plan.Name, Limit: plan.MaxProducts, Used: used}
+	}
+
+	return nil
+}
+
+// CheckPlatformQuota checks adding platforms to the product. The limit is the
+// one of the product creator's plan.
+func CheckPlatformQuota(ctx context.Context, product *models.Product, adding int) error {
+	creator, err := models.GetUserByUserID(ctx, product.UserID)
+	if err != nil {
+		return err
+	}
+	plan := PlanFor(creator)
+
+	used, err := models.CountPlatformsByProductID(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	if exceeds(plan.MaxPlatformsPerProduct, used, adding) {
+		return &QuotaError{Status: http.StatusPaymentRequired, Resource: "platforms per product", Plan: plan.Name, Limit: plan.MaxPlatformsPerProduct, Used: used}
+	}
+
+	return nil
+}
+
+// createStatsGeneration records a stats generation within a limit, tests
+// replace it to run without a database
+var createStatsGeneration = models.CreateStatsGenerationWithinLimit
+
+// UseStatsGenerationQuota records a stats generation by the user, or returns
+// a QuotaError if they already used today's allowance. Concurrent requests
+// cannot both take the last generation of the day.
+func UseStatsGenerationQuota(ctx context.Context, user *models.User, productID uuid.UUID) error {
+	plan := PlanFor(user)
+
+	now := time.Now()
+	used, err := createStatsGeneration(ctx, user.ID, productID, startOfDay(now), plan.MaxStatsGenerationsPerDay)
+	if errors.Is(err, models.ErrStatsGenerationLimit) {
+		return &QuotaError{
+			Status:     http.StatusTooManyRequests,
+			Resource:   "stats generations per day",
+			Plan:       plan.Name,
+			Limit:      plan.MaxStatsGenerationsPerDay,
+			Used:       used,
+			RetryAfter: startOfDay(now).Add(24 * time.Hour).Sub(now),
+		}
+	}
+
+	return err
+}
+
+// CheckReviewQuota checks the product creator has room for adding more
+// reviews. Ingestion passes each page's size so no page overshoots the limit.
+func CheckReviewQuota(ctx context.Context, product *models.Product, adding int) error {
+	creator, err := models.GetUserByUserID(ctx, product.UserID)
+	if err != nil {
+		return err
+	}
+	plan := PlanFor(creator)
+
+	used, err := models.CountReviewsByUserID(ctx, creator.ID)
+	if err != nil {
+		return err
+	}
+
+	if exceeds(plan.MaxReviewsStored, used, adding) {
+		return &QuotaError{Status: http.StatusPaymentRequired, Resource: "reviews stored", Plan: plan.Name, Limit: plan.MaxReviewsStored, Used: used}
+	}
+
+	return nil
+}
+
+type Usage struct {
+	Plan                    Plan      `json:"plan"`
+	Products                int       `json:"products"`
+	StatsGenerationsToday   int       `json:"stats_generations_today"`
+	ReviewsStored           int       `json:"reviews_stored"`
+	StatsGenerationsResetAt time.Time `json:"stats_generations_reset_at"`
+}
+
+func GetUsage(ctx context.Context, user *models.User) (*Usage, error) {
+	now := time.Now()
+	usage := Usage{
+		Plan:                    PlanFor(user),
+		StatsGenerationsResetAt: startOfDay(now).Add(24 * time.Hour),
+	}
+
+	var err error
+	if usage.Products, err = models.CountProductsByUserID(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if usage.StatsGenerationsToday, err = models.CountStatsGenerationsSince(ctx, user.ID, startOfDay(now)); err != nil {
+		return nil, err
+	}
+	if usage.ReviewsStored, err = models.CountReviewsByUserID(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+func TestExceeds(t *testing.T) {
+	tests := []struct {
+		name                string
+		limit, used, adding int
+		want                bool
+	}{
+		{"room for the page", 5000, 4980, 20, false},
+		{"page overshoots", 5000, 4990, 20, true},
+		{"limit reached", 5000, 5000, 1, true},
+		{"unlimited", Unlimited, 1000000, 20, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exceeds(tt.limit, tt.used, tt.adding); got != tt.want {
+				t.Errorf("exceeds(%d, %d, %d) = %v, want %v", tt.limit, tt.used, tt.adding, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeStatsGenerations stands in for the stats_generations table
+type fakeStatsGenerations struct {
+	mutex sync.Mutex
+	count int
+}
+
+func (f *fakeStatsGenerations) create(ctx context.Context, userID, productID uuid.UUID, since time.Time, limit int) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	used := f.count
+	if limit >= 0 && used >= limit {
+		return used, models.ErrStatsGenerationLimit
+	}
+	f.count++
+	return used, nil
+}
+
+func TestUseStatsGenerationQuotaLimit(t *testing.T) {
+	tests := []struct {
+		plan        consts.PlanType
+		requests    int
+		wantAllowed int
+	}{
+		{consts.PlanFree, 20, 5},
+		{consts.PlanFree, 5, 5},
+		{consts.PlanPro, 60, 50},
+		{consts.PlanEnterprise, 200, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s plan, %d requests", tt.plan, tt.requests), func(t *testing.T) {
+			generations := &fakeStatsGenerations{}
+			createStatsGeneration = generations.create
+			t.Cleanup(func() { createStatsGeneration = models.CreateStatsGenerationWithinLimit })
+
+			user := &models.User{ID: uuid.New(), Plan: tt.plan}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, tt.requests)
+			for i := 0; i < tt.requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- UseStatsGenerationQuota(context.Background(), user, uuid.New())
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			allowed := 0
+			for err := range errs {
+				var quotaErr *QuotaError
+				switch {
+				case err == nil:
+					allowed++
+				case errors.As(err, &quotaErr):
+					if quotaErr.Status != http.StatusTooManyRequests || quotaErr.Used != quotaErr.Limit || quotaErr.RetryAfter <= 0 {
+						t.Errorf("got quota error %+v", quotaErr)
+					}
+				default:
+					t.Errorf("UseStatsGenerationQuota: %v", err)
+				}
+			}
+
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d generations, want %d", allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/prompts"
+)
+
+const (
+	defaultReplyTone = "polite, empathetic and professional"
+	maxReplyWords    = 120
+)
+
+// GenerateReplyDraft drafts an owner reply to a review in the product's brand
+// tone. settings may be nil, in which case a neutral tone is used.
+func GenerateReplyDraft(ctx context.Context, product *models.Product, settings *models.BrandToneSettings, review *models.Review) (string, error) {
+	data := prompts.ReplyData{
+		ProductDescription: product.Description,
+		Review: prompts.ReviewData{
+			RatingValue: review.RatingValue,
+			Headline:    review.Headline,
+			ReviewBody:  review.ReviewBody,
+		},
+		Tone:     defaultReplyTone,
+		MaxWords: maxReplyWords,
+	}
+	if settings != nil {
+		data.Tone = settings.Tone
+		data.Guidelines = settings.Guidelines
+		data.Signature = settings.Signature
+	}
+
+	prompt, err := prompts.Get(prompts.Reply)
+	if err != nil {
+		return "", err
+	}
+
+	messages, err := prompt.Messages(data)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := callLLMAPI(ctx, messages, ConfiguredLLMProvider(), os.Getenv("GROQ_API_KEY_REPLY"), LLMOperationReply)
+	if err != nil {
+		return "", fmt.Errorf("error calling LLM API: %w", err)
+	}
+
+	return strings.TrimSpace(body), nil
+}
+
+// ReplyModel is the model recorded on drafts produced by GenerateReplyDraft
+func ReplyModel() string {
+	return LLMModel(ConfiguredLLMProvider())
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,7 +14,10 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/consts"
 	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/utils"
 )
 
 type TripAdvisorResponse struct {
@@ -21,45 +26,118 @@ type TripAdvisorResponse struct {
 			LocationID     int    `json:"locationId"`
 			Name           string `json:"name"`
 			ReviewListPage struct {
-				TotalCount int `json:"totalCount"`
-				Reviews    []struct {
-					ID            string `json:"id"`
-					Text          string `json:"text"`
-					Title         string `json:"title"`
-					Rating        int    `json:"rating"`
-					CreatedDate   string `json:"createdDate"`
-					PublishedDate string `json:"publishedDate"`
-					Username      string `json:"username"`
-					UserProfile   struct {
-						DisplayName string `json:"displayName"`
-						Avatar      struct {
-							PhotoSizeDynamic struct {
-								URLTemplate string `json:"urlTemplate"`
-							} `json:"photoSizeDynamic"`
-						} `json:"avatar"`
-					} `json:"userProfile"`
-					MgmtResponse *struct {
-						Text          string `json:"text"`
-						PublishedDate string `json:"publishedDate"`
-						Username      string `json:"username"`
-					} `json:"mgmtResponse"`
-				} `json:"reviews"`
+				TotalCount int                 `json:"totalCount"`
+				Reviews    []TripAdvisorReview `json:"reviews"`
 			} `json:"reviewListPage"`
 		} `json:"locations"`
 	} `json:"data"`
 }
 
-func ScrapeTrustpilot(ctx context.Context, platform *models.Platform, latestReviewDate string) error {
-	// Create HTTP client
-	client := &http.Client{}
+type TripAdvisorReview struct {
+	ID            string `json:"id"`
+	Text          string `json:"text"`
+	Title         string `json:"title"`
+	Rating        int    `json:"rating"`
+	CreatedDate   string `json:"createdDate"`
+	PublishedDate string `json:"publishedDate"`
+	Username      string `json:"username"`
+	UserProfile   struct {
+		DisplayName string `json:"displayName"`
+		Avatar      struct {
+			PhotoSizeDynamic struct {
+				URLTemplate string `json:"urlTemplate"`
+			} `json:"photoSizeDynamic"`
+		} `json:"avatar"`
+	} `json:"userProfile"`
+	MgmtResponse *struct {
+		Text          string `json:"text"`
+		PublishedDate string `json:"publishedDate"`
+		Username      string `json:"username"`
+	} `json:"mgmtResponse"`
+}
+
+// tripadvisorScrapeReviewsCount caps how many reviews a single Tripadvisor run fetches
+const tripadvisorScrapeReviewsCount = 100
+
+// scraperServiceClient calls our own scraper service, which does its own
+// crawling, so it skips the proxies and cookies of ScraperFetcher
+var scraperServiceClient = &http.Client{Timeout: 30 * time.Second}
+
+// RunPlatformScraper scrapes a platform in the given mode, resuming from the
+// platform's stored cursor for that mode.
+func RunPlatformScraper(ctx context.Context, platform *models.Platform, mode consts.ScrapeModeType) error {
+	switch platform.Name {
+	case consts.PlatformTrustpilot:
+		cursor, err := models.GetScrapeCursor(ctx, platform.ID, mode)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error getting scrape cursor: %w", err)
+		}
+
+		var cursorValue string
+		if cursor != nil {
+			cursorValue = cursor.Cursor
+		}
+
+		return ScrapeTrustpilot(ctx, platform, mode, cursorValue)
+	case consts.PlatformTripadvisor:
+		_, err := ScrapeTripadvisor(ctx, platform, mode, tripadvisorScrapeReviewsCount)
+		return err
+	default:
+		return fmt.Errorf("unsupported platform: %s", platform.Name)
+	}
+}
+
+// ScrapeTrustpilot asks the scraper service to scrape a Trustpilot platform.
+// The scraper service owns the format of cursor; it posts reviews back along
+// with the cursor for the next page, which is stored as it arrives.
+func ScrapeTrustpilot(ctx context.Context, platform *models.Platform, mode consts.ScrapeModeType, cursor string) error {
+	return requestTrustpilotScrape(ctx, map[string]interface{}{
+		"platform_id":  platform.ID.String(),
+		"platform_url": platform.URL,
+		"mode":         string(mode),
+		"cursor":       cursor,
+	})
+}
+
+// ScrapeTrustpilotBackfill asks the scraper service to run a backfill job. The
+// service stops at the job's targets, waits page_delay_seconds between pages
+// and reports each page back with the job ID.
+func ScrapeTrustpilotBackfill(ctx context.Context, platform *models.Platform, job *models.BackfillJob, cursor string) error {
+	requestBody := map[string]interface{}{
+		"platform_id":        platform.ID.String(),
+		"platform_url":       platform.URL,
+		"mode":               string(consts.ScrapeModeBackfill),
+		"cursor":             cursor,
+		"backfill_job_id":    job.ID.String(),
+		"page_delay_seconds": job.PageDelaySeconds,
+	}
+
+	if job.TargetReviews != nil {
+		requestBody["max_reviews"] = *job.TargetReviews - job.ReviewsFetched
+	}
+	if job.TargetSince != nil {
+		requestBody["since"] = job.TargetSince.Format(time.RFC3339)
+	}
 
-	// Create request body
-	requestBody := map[string]string{
-		"platform_id":      platform.ID.String(),
-		"platform_url":     platform.URL,
-		"last_review_date": latestReviewDate,
+	return requestTrustpilotScrape(ctx, requestBody)
+}
+
+// signInternalRequest signs a request to the scraper service the same way the
+// service signs its callbacks to our internal routes
+func signInternalRequest(req *http.Request, body []byte) {
+	if config.Config.InternalHMACSecret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := uuid.NewString()
+		req.Header.Set(utils.HeaderSignatureTimestamp, timestamp)
+		req.Header.Set(utils.HeaderSignatureNonce, nonce)
+		req.Header.Set(utils.HeaderSignature, utils.SignRequest(config.Config.InternalHMACSecret, timestamp, nonce, req.Method, req.URL.RequestURI(), body))
+		return
 	}
 
+	req.Header.Set("Authorization", "Bearer "+config.Config.InternalAuthToken)
+}
+
+func requestTrustpilotScrape(ctx context.Context, requestBody map[string]interface{}) error {
 	fmt.Println("Request body", requestBody)
 
 	// Marshal the request body to JSON
@@ -77,9 +155,10 @@ func ScrapeTrustpilot(ctx context.Context, platform *models.Platform, latestRevi
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
+	signInternalRequest(req, jsonBody)
 
 	// Send request
-	resp, err := client.Do(req)
+	resp, err := scraperServiceClient.Do(req)
 	if err != nil {
 		fmt.Println("Error while sending request", err)
 		return fmt.Errorf("error sending request: %v", err)
@@ -95,125 +174,279 @@ func ScrapeTrustpilot(ctx context.Context, platform *models.Platform, latestRevi
 	return nil
 }
 
-func ScrapeTripadvisor(ctx context.Context, platform *models.Platform, latestReviewDate string, scrapeReviewsCount int) ([]*models.Review, error) {
-	client := &http.Client{}
-	locationID := extractLocationID(platform.URL)
+// tripadvisorCursor is the decoded form of a Tripadvisor scrape cursor.
+type tripadvisorCursor struct {
+	// Offset is the next page to fetch while a run is in progress.
+	Offset int `json:"offset"`
+	// InProgress is set until a run reaches StopBefore or the last page.
+	InProgress bool `json:"in_progress"`
+	// StopBefore is the date an incremental run stops at, fixed when the run starts.
+	StopBefore time.Time `json:"stop_before"`
+	// Newest is the most recent review date seen across runs.
+	Newest time.Time `json:"newest"`
+}
+
+func encodeTripadvisorCursor(cursor tripadvisorCursor) (string, error) {
+	cursorJSON, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cursorJSON), nil
+}
+
+func decodeTripadvisorCursor(value string) (tripadvisorCursor, error) {
+	var cursor tripadvisorCursor
+	if value == "" {
+		return cursor, nil
+	}
+
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+
+	err = json.Unmarshal(cursorJSON, &cursor)
+	return cursor, err
+}
+
+// ScrapeTripadvisor fetches up to scrapeReviewsCount reviews for the platform.
+// Reviews and the cursor are stored after every page, so a run that is
+// interrupted or hits scrapeReviewsCount continues from the same offset next
+// time. Incremental runs stop at the newest review seen by the previous
+// completed run; backfill runs walk back to the oldest review.
+func ScrapeTripadvisor(ctx context.Context, platform *models.Platform, mode consts.ScrapeModeType, scrapeReviewsCount int) ([]*models.Review, error) {
+	fetcher := ScraperFetcher()
 	limit := 20 // TripAdvisor's default limit
-	offset := 0
 	allReviews := make([]*models.Review, 0)
 
-	for {
-		// Break if we've collected enough reviews
-		if len(allReviews) >= scrapeReviewsCount {
-			break
-		}
-
+	for len(allReviews) < scrapeReviewsCount {
 		// Adjust limit for last batch if needed
-		remainingCount := scrapeReviewsCount - len(allReviews)
-		if remainingCount < limit {
-			limit = remainingCount
-		}
-
-		requestBody := []map[string]interface{}{
-			{
-				"variables": map[string]interface{}{
-					"locationId": locationID,
-					"offset":     offset,
-					"limit":      limit,
-					"language":   "en",
-					"filters": []map[string]interface{}{
-						{
-							"axis":       "LANGUAGE",
-							"selections": []string{"en"},
-						},
-						{
-							"axis":       "SORT",
-							"selections": []string{"mostRecent"},
-						},
-					},
-					"prefs": map[string]interface{}{
-						"showMT":   true,
-						"sortBy":   "DATE",
-						"sortType": "",
-					},
-				},
-				"extensions": map[string]interface{}{
-					"preRegisteredQueryId": "aaff0337570ed0aa",
-				},
-			},
+		pageLimit := limit
+		if remainingCount := scrapeReviewsCount - len(allReviews); remainingCount < pageLimit {
+			pageLimit = remainingCount
 		}
 
-		jsonBody, err := json.Marshal(requestBody)
+		reviews, done, err := ScrapeTripadvisorPage(ctx, fetcher, platform, mode, time.Time{}, pageLimit)
+		allReviews = append(allReviews, reviews...)
 		if err != nil {
-			return nil, fmt.Errorf("error marshaling request body: %w", err)
+			return allReviews, err
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", "https://www.tripadvisor.in/data/graphql/ids", bytes.NewBuffer(jsonBody))
-		if err != nil {
-			return nil, fmt.Errorf("error creating request: %w", err)
+		if done {
+			break
 		}
+	}
 
-		req.Header.Set("Content-Type", "application/json")
-		// Add any required cookies or headers
-		req.Header.Set("Cookie", "YOUR_COOKIE_HERE") // You'll need to handle this securely
+	return allReviews, nil
+}
+
+// ScrapeTripadvisorPage fetches and stores the next page of reviews for the
+// platform and checkpoints the cursor. Backfill runs stop at reviews older than
+// since when it is set. done is true once the run has nothing left to fetch.
+func ScrapeTripadvisorPage(ctx context.Context, fetcher *Fetcher, platform *models.Platform, mode consts.ScrapeModeType, since time.Time, limit int) ([]*models.Review, bool, error) {
+	locationID := extractLocationID(platform.URL)
 
-		resp, err := client.Do(req)
+	storedCursor, err := models.GetScrapeCursor(ctx, platform.ID, mode)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("error getting scrape cursor: %w", err)
+	}
+
+	var cursor tripadvisorCursor
+	if storedCursor != nil {
+		cursor, err = decodeTripadvisorCursor(storedCursor.Cursor)
 		if err != nil {
-			return nil, fmt.Errorf("error sending request: %w", err)
+			return nil, false, fmt.Errorf("error decoding scrape cursor: %w", err)
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if !cursor.InProgress {
+		cursor.Offset = 0
+		cursor.InProgress = true
+		if mode == consts.ScrapeModeIncremental {
+			cursor.StopBefore = cursor.Newest
 		}
+	}
+	if mode == consts.ScrapeModeBackfill {
+		cursor.StopBefore = since
+	}
 
-		var response []TripAdvisorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			return nil, fmt.Errorf("error decoding response: %w", err)
+	page, totalCount, err := fetchTripadvisorPage(ctx, fetcher, locationID, cursor.Offset, limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	reviews, reachedStop := parseTripadvisorPage(page, locationID, &cursor)
+
+	if len(reviews) > 0 {
+		// Checked per page so long runs stop at the plan limit. The cursor is
+		// left in place to resume from this page once there is room.
+		product, err := models.GetProductByID(ctx, platform.ProductID)
+		if err != nil {
+			return nil, false, fmt.Errorf("error getting product: %w", err)
+		}
+		if err := CheckReviewQuota(ctx, product, len(reviews)); err != nil {
+			return nil, false, err
 		}
 
-		if len(response) == 0 || len(response[0].Data.Locations) == 0 {
-			break
+		if err := models.CreateReviews(ctx, reviews, platform.ID); err != nil {
+			return nil, false, fmt.Errorf("error creating reviews: %w", err)
 		}
+	}
 
-		location := response[0].Data.Locations[0]
-		reviews := location.ReviewListPage.Reviews
-		totalCount := location.ReviewListPage.TotalCount
+	advanceTripadvisorCursor(&cursor, len(page), totalCount, reachedStop)
 
-		// Convert to review model
-		for _, review := range reviews {
-			publishedDate, _ := time.Parse("2006-01-02", review.PublishedDate)
+	if err := saveTripadvisorCursor(ctx, platform.ID, mode, cursor); err != nil {
+		return reviews, false, err
+	}
 
-			// Check if we've reached reviews older than latestReviewDate
-			if latestReviewDate != "" {
-				lastReviewDate, _ := time.Parse(time.RFC3339, latestReviewDate)
-				if publishedDate.Before(lastReviewDate) {
-					return allReviews, nil
-				}
-			}
+	return reviews, !cursor.InProgress, nil
+}
 
-			allReviews = append(allReviews, &models.Review{
-				ID:            uuid.New(),
-				AuthorName:    review.UserProfile.DisplayName,
-				RatingValue:   float64(review.Rating),
-				ReviewBody:    review.Text,
-				DatePublished: publishedDate.Format(time.RFC3339),
-			})
-
-			// Check if we've reached the desired count
-			if len(allReviews) >= scrapeReviewsCount {
-				return allReviews, nil
-			}
+// parseTripadvisorPage converts a page of reviews, newest first, until the
+// first one published before cursor.StopBefore and records the newest date
+// seen on cursor. reachedStop is true when the page went past the stop date.
+func parseTripadvisorPage(page []TripAdvisorReview, locationID int, cursor *tripadvisorCursor) ([]*models.Review, bool) {
+	reviews := make([]*models.Review, 0, len(page))
+	reachedStop := false
+	for _, review := range page {
+		publishedDate, err := time.Parse("2006-01-02", review.PublishedDate)
+		if err != nil {
+			log.Warn("Skipping tripadvisor review with invalid published date: ", review.ID, review.PublishedDate)
+			continue
 		}
 
-		// Check if we've fetched all available reviews
-		if offset+limit >= totalCount {
+		// Reviews on the stop date are fetched again and deduplicated by URL on insert
+		if publishedDate.Before(cursor.StopBefore) {
+			reachedStop = true
 			break
 		}
-		offset += limit
+
+		if publishedDate.After(cursor.Newest) {
+			cursor.Newest = publishedDate
+		}
+
+		scrapedReview := &models.Review{
+			ID:            uuid.New(),
+			Url:           fmt.Sprintf("https://www.tripadvisor.in/ShowUserReviews-d%d-r%s", locationID, review.ID),
+			AuthorName:    review.UserProfile.DisplayName,
+			Headline:      review.Title,
+			RatingValue:   float64(review.Rating),
+			ReviewBody:    review.Text,
+			DatePublished: publishedDate.Format(time.RFC3339),
+		}
+
+		if response := review.MgmtResponse; response != nil && response.Text != "" {
+			scrapedReview.ResponseBody = &response.Text
+			scrapedReview.ResponseAuthor = &response.Username
+			if responseDate, err := time.Parse("2006-01-02", response.PublishedDate); err == nil {
+				formattedDate := responseDate.Format(time.RFC3339)
+				scrapedReview.ResponseDate = &formattedDate
+			} else {
+				log.Warn("Ignoring invalid tripadvisor response date: ", review.ID, response.PublishedDate)
+			}
+		}
+
+		reviews = append(reviews, scrapedReview)
 	}
 
-	return allReviews, nil
+	return reviews, reachedStop
+}
+
+// advanceTripadvisorCursor moves the cursor past a page of pageSize reviews,
+// ending the run once it reached the stop date or the last page
+func advanceTripadvisorCursor(cursor *tripadvisorCursor, pageSize, totalCount int, reachedStop bool) {
+	cursor.Offset += pageSize
+	if reachedStop || pageSize == 0 || cursor.Offset >= totalCount {
+		cursor.Offset = 0
+		cursor.InProgress = false
+	}
+}
+
+func saveTripadvisorCursor(ctx context.Context, platformID uuid.UUID, mode consts.ScrapeModeType, cursor tripadvisorCursor) error {
+	cursorValue, err := encodeTripadvisorCursor(cursor)
+	if err != nil {
+		return fmt.Errorf("error encoding scrape cursor: %w", err)
+	}
+
+	err = models.UpsertScrapeCursor(ctx, &models.ScrapeCursor{
+		PlatformID: platformID,
+		Mode:       mode,
+		Cursor:     cursorValue,
+	})
+	if err != nil {
+		return fmt.Errorf("error saving scrape cursor: %w", err)
+	}
+
+	return nil
+}
+
+// fetchTripadvisorPage fetches one page of reviews, newest first, and returns
+// them along with the total number of reviews for the location.
+func fetchTripadvisorPage(ctx context.Context, fetcher *Fetcher, locationID, offset, limit int) ([]TripAdvisorReview, int, error) {
+	requestBody := []map[string]interface{}{
+		{
+			"variables": map[string]interface{}{
+				"locationId": locationID,
+				"offset":     offset,
+				"limit":      limit,
+				"language":   "en",
+				"filters": []map[string]interface{}{
+					{
+						"axis":       "LANGUAGE",
+						"selections": []string{"en"},
+					},
+					{
+						"axis":       "SORT",
+						"selections": []string{"mostRecent"},
+					},
+				},
+				"prefs": map[string]interface{}{
+					"showMT":   true,
+					"sortBy":   "DATE",
+					"sortType": "",
+				},
+			},
+			"extensions": map[string]interface{}{
+				"preRegisteredQueryId": "aaff0337570ed0aa",
+			},
+		},
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error marshaling request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://www.tripadvisor.in/data/graphql/ids", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	// Session cookies come from the fetcher's jar, seeded by SCRAPER_COOKIES
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var response []TripAdvisorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, 0, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if len(response) == 0 || len(response[0].Data.Locations) == 0 {
+		return nil, 0, nil
+	}
+
+	location := response[0].Data.Locations[0]
+	return location.ReviewListPage.Reviews, location.ReviewListPage.TotalCount, nil
 }
 
 func extractLocationID(url string) int {
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const fixturesDir = "testdata/fixtures"
+
+func TestFetchAndParseTripadvisorPage(t *testing.T) {
+	fetcher, err := NewFetcher(FetcherConfig{ReplayDir: fixturesDir})
+	if err != nil {
+		t.Fatalf("NewFetcher: %v", err)
+	}
+
+	page, totalCount, err := fetchTripadvisorPage(context.Background(), fetcher, 302193, 0, 3)
+	if err != nil {
+		t.Fatalf("fetchTripadvisorPage: %v", err)
+	}
+	if len(page) != 3 || totalCount != 3 {
+		t.Fatalf("got %d reviews of %d, want 3 of 3", len(page), totalCount)
+	}
+
+	cursor := tripadvisorCursor{InProgress: true, StopBefore: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	reviews, reachedStop := parseTripadvisorPage(page, 302193, &cursor)
+
+	if !reachedStop {
+		t.Error("reachedStop = false, want true for the review published before StopBefore")
+	}
+	if len(reviews) != 2 {
+		t.Fatalf("got %d reviews, want the 2 published on or after StopBefore", len(reviews))
+	}
+	if want := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC); !cursor.Newest.Equal(want) {
+		t.Errorf("cursor.Newest = %v, want %v", cursor.Newest, want)
+	}
+
+	newest := reviews[0]
+	if newest.Url != "https://www.tripadvisor.in/ShowUserReviews-d302193-r951234567" {
+		t.Errorf("Url = %q", newest.Url)
+	}
+	if newest.AuthorName != "Meera K" || newest.Headline != "Lovely stay by the beach" || newest.RatingValue != 5 {
+		t.Errorf("got author %q, headline %q, rating %v", newest.AuthorName, newest.Headline, newest.RatingValue)
+	}
+	if newest.DatePublished != "2026-03-10T00:00:00Z" {
+		t.Errorf("DatePublished = %q", newest.DatePublished)
+	}
+	if newest.ResponseBody != nil {
+		t.Errorf("ResponseBody = %q, want nil", *newest.ResponseBody)
+	}
+
+	answered := reviews[1]
+	if answered.ResponseBody == nil || answered.ResponseAuthor == nil || answered.ResponseDate == nil {
+		t.Fatal("owner response was not parsed")
+	}
+	if *answered.ResponseAuthor != "Seaside Inn Manager" || *answered.ResponseDate != "2026-03-09T00:00:00Z" {
+		t.Errorf("got response author %q, date %q", *answered.ResponseAuthor, *answered.ResponseDate)
+	}
+}
+
+func TestAdvanceTripadvisorCursor(t *testing.T) {
+	tests := []struct {
+		name        string
+		offset      int
+		pageSize    int
+		totalCount  int
+		reachedStop bool
+		wantOffset  int
+		wantRunning bool
+	}{
+		{name: "more pages", offset: 0, pageSize: 20, totalCount: 100, wantOffset: 20, wantRunning: true},
+		{name: "last page", offset: 80, pageSize: 20, totalCount: 100, wantOffset: 0, wantRunning: false},
+		{name: "reached stop date", offset: 0, pageSize: 20, totalCount: 100, reachedStop: true, wantOffset: 0, wantRunning: false},
+		{name: "empty page", offset: 40, pageSize: 0, totalCount: 100, wantOffset: 0, wantRunning: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor := tripadvisorCursor{Offset: test.offset, InProgress: true}
+			advanceTripadvisorCursor(&cursor, test.pageSize, test.totalCount, test.reachedStop)
+
+			if cursor.Offset != test.wantOffset || cursor.InProgress != test.wantRunning {
+				t.Errorf("got offset %d, in progress %v, want %d, %v", cursor.Offset, cursor.InProgress, test.wantOffset, test.wantRunning)
+			}
+		})
+	}
+}
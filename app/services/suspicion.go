@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/prompts"
+)
+
+// How much each signal adds to a review's suspicion. Signals are combined as
+// independent evidence, 1 - (1 - a)(1 - b)..., so a single signal does not
+// make a review suspicious under the default threshold but two usually do.
+var suspicionWeights = map[consts.SuspicionReasonType]float64{
+	consts.SuspicionReasonDuplicateText:   0.6,
+	consts.SuspicionReasonBurst:           0.3,
+	consts.SuspicionReasonShortGeneric:    0.35,
+	consts.SuspicionReasonReviewerSameDay: 0.5,
+}
+
+const (
+	// Reviews shorter than this are too common to count as copied text,
+	// many genuine reviews just say "Great product"
+	minDuplicateTextLength = 20
+	// A review is short when it has at most shortReviewWords words, and
+	// generic when at least half of them are in genericReviewWords
+	shortReviewWords = 5
+	// A platform's day is a burst when it has at least burstMinReviews and
+	// burstFactor times the platform's average daily reviews
+	burstMinReviews = 5
+	burstFactor     = 5.0
+	// A reviewer posting sameDayMinReviews reviews of the product in a day
+	sameDayMinReviews = 2
+	// The LLM is asked about reviews scoring at least suspicionLLMMinScore
+	// by the heuristics, at most maxSuspicionLLMReviews per run and
+	// suspicionLLMBatchSize per call
+	suspicionLLMMinScore    = 0.25
+	maxSuspicionLLMReviews  = 60
+	suspicionLLMBatchSize   = 20
+	suspicionLLMReasonScore = 0.5
+)
+
+var genericReviewWords = map[string]bool{
+	"good": true, "great": true, "nice": true, "excellent": true, "awesome": true, "amazing": true,
+	"best": true, "perfect": true, "super": true, "love": true, "loved": true, "wonderful": true,
+	"bad": true, "worst": true, "poor": true, "terrible": true, "awful": true, "ok": true, "okay": true,
+	"very": true, "really": true, "so": true, "the": true, "a": true, "it": true, "is": true, "was": true,
+	"this": true, "product": true, "place": true, "service": true, "experience": true, "recommended": true,
+	"highly": true, "must": true, "buy": true, "value": true, "money": true, "quality": true, "stars": true,
+}
+
+// ScoreReviewSuspicion scores every review of the product for signs of being
+// fake or spam and stores the scores on the reviews. With
+// config.Config.SuspicionLLMEnabled the LLM also judges borderline reviews.
+func ScoreReviewSuspicion(ctx context.Context, product *models.Product) error {
+	reviews, err := models.GetReviewsByProductID(ctx, product.ID)
+	if err != nil {
+		return fmt.Errorf("error getting reviews: %w", err)
+	}
+
+	scores := scoreReviews(reviews)
+
+	if config.Config.SuspicionLLMEnabled {
+		if err := judgeReviews(ctx, product, reviews, scores); err != nil {
+			// The heuristic scores are still worth storing
+			log.Error("Error while judging reviews with LLM", err)
+		}
+	}
+
+	return models.UpdateReviewSuspicion(ctx, scores)
+}
+
+// scoreReviews scores the reviews, which must be all of a product's reviews
+// for duplicates and bursts to be found, with the heuristics alone. An
+// earlier LLM score of a review is kept and averaged in.
+func scoreReviews(reviews []*models.Review) []*models.ReviewSuspicion {
+	reasons := make(map[uuid.UUID][]consts.SuspicionReasonType, len(reviews))
+	addReason := func(review *models.Review, reason consts.SuspicionReasonType) {
+		reasons[review.ID] = append(reasons[review.ID], reason)
+	}
+
+	texts := map[string][]*models.Review{}
+	platformDays := map[uuid.UUID]map[string][]*models.Review{}
+	authorDays := map[string][]*models.Review{}
+	for _, review := range reviews {
+		words := reviewWords(review.ReviewBody)
+
+		if text := strings.Join(words, " "); len(text) >= minDuplicateTextLength {
+			texts[text] = append(texts[text], review)
+		}
+
+		if isShortGeneric(words) {
+			addReason(review, consts.SuspicionReasonShortGeneric)
+		}
+
+		day := reviewDate(review.DatePublished)
+		if platformDays[review.PlatformID] == nil {
+			platformDays[review.PlatformID] = map[string][]*models.Review{}
+		}
+		platformDays[review.PlatformID][day] = append(platformDays[review.PlatformID][day], review)
+
+		if author := strings.ToLower(strings.TrimSpace(review.AuthorName)); author != "" && !isAnonymousAuthor(author) {
+			authorDays[author+"|"+day] = append(authorDays[author+"|"+day], review)
+		}
+	}
+
+	for _, duplicates := range texts {
+		if len(duplicates) > 1 {
+			for _, review := range duplicates {
+				addReason(review, consts.SuspicionReasonDuplicateText)
+			}
+		}
+	}
+
+	for _, days := range platformDays {
+		for _, review := range burstReviews(days) {
+			addReason(review, consts.SuspicionReasonBurst)
+		}
+	}
+
+	for _, authorReviews := range authorDays {
+		if len(authorReviews) >= sameDayMinReviews {
+			for _, review := range authorReviews {
+				addReason(review, consts.SuspicionReasonReviewerSameDay)
+			}
+		}
+	}
+
+	scores := make([]*models.ReviewSuspicion, 0, len(reviews))
+	for _, review := range reviews {
+		score := &models.ReviewSuspicion{ReviewID: review.ID, Reasons: []string{}, LLMScore: review.SuspicionLLMScore}
+
+		genuine := 1.0
+		for _, reason := range reasons[review.ID] {
+			genuine *= 1 - suspicionWeights[reason]
+			score.Reasons = append(score.Reasons, string(reason))
+		}
+		score.Score = 1 - genuine
+
+		applyLLMScore(score)
+		scores = append(scores, score)
+	}
+
+	return scores
+}
+
+// burstReviews returns the extreme ratings posted on a platform's burst days.
+// Genuine spikes, e.g. after a launch, bring mixed ratings while review
+// bombing and paid campaigns are one-sided.
+func burstReviews(days map[string][]*models.Review) []*models.Review {
+	var firstDay, lastDay string
+	total := 0
+	for day, dayReviews := range days {
+		if firstDay == "" || day < firstDay {
+			firstDay = day
+		}
+		if day > lastDay {
+			lastDay = day
+		}
+		total += len(dayReviews)
+	}
+
+	span := 1.0
+	first, firstErr := time.Parse(time.DateOnly, firstDay)
+	last, lastErr := time.Parse(time.DateOnly, lastDay)
+	if firstErr == nil && lastErr == nil {
+		span = max(span, last.Sub(first).Hours()/24+1)
+	}
+	dailyAverage := float64(total) / span
+
+	var burst []*models.Review
+	for _, dayReviews := range days {
+		if len(dayReviews) < burstMinReviews || float64(len(dayReviews)) < burstFactor*dailyAverage {
+			continue
+		}
+		for _, review := range dayReviews {
+			if review.RatingValue <= 1.5 || review.RatingValue >= 4.5 {
+				burst = append(burst, review)
+			}
+		}
+	}
+
+	return burst
+}
+
+// reviewWords returns the lowercase words of a review
+func reviewWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func isShortGeneric(words []string) bool {
+	if len(words) > shortReviewWords {
+		return false
+	}
+
+	generic := 0
+	for _, word := range words {
+		if genericReviewWords[word] {
+			generic++
+		}
+	}
+
+	return generic*2 >= len(words)
+}
+
+// isAnonymousAuthor reports names platforms show for every hidden reviewer
+func isAnonymousAuthor(author string) bool {
+	switch author {
+	case "anonymous", "a customer", "customer", "guest", "traveler", "traveller", "user":
+		return true
+	}
+
+	return false
+}
+
+// applyLLMScore averages the LLM's judgment into the heuristic score
+func applyLLMScore(score *models.ReviewSuspicion) {
+	if score.LLMScore == nil {
+		return
+	}
+
+	score.Score = (score.Score + *score.LLMScore) / 2
+	if *score.LLMScore >= suspicionLLMReasonScore {
+		score.Reasons = append(score.Reasons, string(consts.SuspicionReasonLLMJudgment))
+	}
+}
+
+// judgeReviews asks the LLM about the reviews the heuristics found somewhat
+// suspicious and that it has not judged before
+func judgeReviews(ctx context.Context, product *models.Product, reviews []*models.Review, scores []*models.ReviewSuspicion) error {
+	var candidates []*models.Review
+	candidateScores := map[uuid.UUID]*models.ReviewSuspicion{}
+	for i, review := range reviews {
+		if scores[i].LLMScore == nil && scores[i].Score >= suspicionLLMMinScore && len(candidates) < maxSuspicionLLMReviews {
+			candidates = append(candidates, review)
+			candidateScores[review.ID] = scores[i]
+		}
+	}
+
+	for start := 0; start < len(candidates); start += suspicionLLMBatchSize {
+		batch := candidates[start:min(start+suspicionLLMBatchSize, len(candidates))]
+
+		llmScores, err := judgeReviewBatch(ctx, product, batch)
+		if err != nil {
+			return err
+		}
+
+		for reviewID, llmScore := range llmScores {
+			if score, ok := candidateScores[reviewID]; ok {
+				score.LLMScore = &llmScore
+				applyLLMScore(score)
+			}
+		}
+	}
+
+	return nil
+}
+
+// judgeReviewBatch returns the LLM's score of each review it judged
+func judgeReviewBatch(ctx context.Context, product *models.Product, reviews []*models.Review) (map[uuid.UUID]float64, error) {
+	prompt, err := prompts.Get(prompts.Suspicion)
+	if err != nil {
+		return nil, err
+	}
+
+	data := prompts.SuspicionData{ProductDescription: product.Description}
+	for _, review := range reviews {
+		data.Reviews = append(data.Reviews, prompts.CitedReviewData{
+			ID:            review.ID.String(),
+			DatePublished: reviewDate(review.DatePublished),
+			RatingValue:   review.RatingValue,
+			Headline:      review.Headline,
+			ReviewBody:    review.ReviewBody,
+		})
+	}
+
+	messages, err := prompt.Messages(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := callLLMAPI(ctx, messages, ConfiguredLLMProvider(), os.Getenv("GROQ_API_KEY_SUSPICION"), LLMOperationSuspicion)
+	if err != nil {
+		return nil, fmt.Errorf("error calling LLM API: %w", err)
+	}
+
+	var judgment struct {
+		Reviews []struct {
+			ID    string  `json:"id"`
+			Score float64 `json:"score"`
+		} `json:"reviews"`
+	}
+	if err := json.Unmarshal([]byte(body), &judgment); err != nil {
+		return nil, fmt.Errorf("%w: error unmarshalling suspicion scores: %w", ErrInvalidLLMOutput, err)
+	}
+
+	llmScores := make(map[uuid.UUID]float64, len(judgment.Reviews))
+	for _, review := range judgment.Reviews {
+		reviewID, err := uuid.Parse(strings.Trim(strings.TrimSpace(review.ID), "[]"))
+		if err != nil {
+			continue
+		}
+		llmScores[reviewID] = min(max(review.Score, 0), 1)
+	}
+
+	return llmScores, nil
+}
+
+// SuspicionThreshold returns the suspicion score from which reviews are left
+// out, or 0 to keep every review
+func SuspicionThreshold(excludeSuspicious bool) float64 {
+	if !excludeSuspicious {
+		return 0
+	}
+
+	return config.Config.SuspicionThreshold
+}
+
+// ExcludeSuspiciousReviews drops the reviews scoring the threshold or higher
+// when config.Config.ExcludeSuspiciousReviews is set
+func ExcludeSuspiciousReviews(reviews []*models.Review) []*models.Review {
+	threshold := SuspicionThreshold(config.Config.ExcludeSuspiciousReviews)
+	if threshold == 0 {
+		return reviews
+	}
+
+	kept := make([]*models.Review, 0, len(reviews))
+	for _, review := range reviews {
+		if review.SuspicionScore == nil || *review.SuspicionScore < threshold {
+			kept = append(kept, review)
+		}
+	}
+
+	return kept
+}
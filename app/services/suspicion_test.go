@@ -0,0 +1,249 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/consts"
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+// testReview builds a review of the platform on a day of January 2026
+func testReview(platformID uuid.UUID, day int, rating float64, author, body string) *models.Review {
+	return &models.Review{
+		ID:            uuid.New(),
+		PlatformID:    platformID,
+		AuthorName:    author,
+		DatePublished: fmt.Sprintf("2026-01-%02dT10:00:00Z", day),
+		RatingValue:   rating,
+		ReviewBody:    body,
+	}
+}
+
+func scoreOf(scores []*models.ReviewSuspicion, review *models.Review) *models.ReviewSuspicion {
+	for _, score := range scores {
+		if score.ReviewID == review.ID {
+			return score
+		}
+	}
+
+	return nil
+}
+
+func TestScoreReviews(t *testing.T) {
+	platformID := uuid.New()
+	const copied = "The battery died after two weeks of light use"
+
+	tests := []struct {
+		name    string
+		reviews []*models.Review
+		// reasons of the first review
+		want []string
+	}{
+		{
+			name: "duplicate text",
+			reviews: []*models.Review{
+				testReview(platformID, 3, 1, "Ann", copied),
+				testReview(platformID, 9, 1, "Bob", "  "+copied+"!"),
+			},
+			want: []string{string(consts.SuspicionReasonDuplicateText)},
+		},
+		{
+			name: "duplicate text below the minimum length",
+			reviews: []*models.Review{
+				testReview(platformID, 3, 4, "Ann", "Fast shipping, fine"),
+				testReview(platformID, 9, 4, "Bob", "Fast shipping, fine"),
+			},
+			want: []string{},
+		},
+		{
+			name: "short generic",
+			reviews: []*models.Review{
+				testReview(platformID, 3, 5, "Ann", "Great product!"),
+			},
+			want: []string{string(consts.SuspicionReasonShortGeneric)},
+		},
+		{
+			name: "same reviewer on one day",
+			reviews: []*models.Review{
+				testReview(platformID, 3, 5, "Ann", "Sturdy hinges and a bright screen"),
+				testReview(platformID, 3, 5, " ann ", "Keyboard feels solid for the price"),
+			},
+			want: []string{string(consts.SuspicionReasonReviewerSameDay)},
+		},
+		{
+			name: "anonymous reviewers on one day",
+			reviews: []*models.Review{
+				testReview(platformID, 3, 5, "Anonymous", "Sturdy hinges and a bright screen"),
+				testReview(platformID, 3, 5, "anonymous", "Keyboard feels solid for the price"),
+			},
+			want: []string{},
+		},
+		{
+			name: "reviewers without a name",
+			reviews: []*models.Review{
+				testReview(platformID, 3, 5, "", "Sturdy hinges and a bright screen"),
+				testReview(platformID, 3, 5, "", "Keyboard feels solid for the price"),
+			},
+			want: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score := scoreOf(scoreReviews(test.reviews), test.reviews[0])
+			if !slices.Equal(score.Reasons, test.want) {
+				t.Errorf("reasons = %v, want %v", score.Reasons, test.want)
+			}
+			if len(test.want) == 0 && score.Score != 0 {
+				t.Errorf("score = %v without reasons", score.Score)
+			}
+			if len(test.want) == 1 && score.Score != suspicionWeights[consts.SuspicionReasonType(test.want[0])] {
+				t.Errorf("score = %v, want the weight of %s", score.Score, test.want[0])
+			}
+		})
+	}
+}
+
+func TestScoreReviewsCombinesReasons(t *testing.T) {
+	platformID := uuid.New()
+	reviews := []*models.Review{
+		testReview(platformID, 3, 5, "Ann", "Great product!"),
+		testReview(platformID, 3, 5, "Ann", "Great product!"),
+	}
+
+	score := scoreOf(scoreReviews(reviews), reviews[0])
+
+	genuine := (1 - suspicionWeights[consts.SuspicionReasonShortGeneric]) * (1 - suspicionWeights[consts.SuspicionReasonReviewerSameDay])
+	if want := 1 - genuine; score.Score != want {
+		t.Errorf("score = %v, want %v", score.Score, want)
+	}
+}
+
+func TestBurstReviews(t *testing.T) {
+	platformID := uuid.New()
+
+	// A review a week over a month, then burst reviews on the 30th
+	days := func(burst []float64) map[string][]*models.Review {
+		days := map[string][]*models.Review{}
+		for day := 1; day < 30; day += 7 {
+			date := fmt.Sprintf("2026-01-%02d", day)
+			days[date] = append(days[date], testReview(platformID, day, 4, "", ""))
+		}
+		for _, rating := range burst {
+			days["2026-01-30"] = append(days["2026-01-30"], testReview(platformID, 30, rating, "", ""))
+		}
+		return days
+	}
+
+	tests := []struct {
+		name  string
+		burst []float64
+		want  int
+	}{
+		{name: "one-sided ratings", burst: []float64{1, 1, 1, 1, 1, 1}, want: 6},
+		{name: "mixed ratings", burst: []float64{1, 5, 3, 2, 4, 3}, want: 2},
+		{name: "below the minimum reviews", burst: []float64{1, 1, 1, 1}, want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			burst := burstReviews(days(test.burst))
+			if len(burst) != test.want {
+				t.Fatalf("got %d burst reviews, want %d", len(burst), test.want)
+			}
+			for _, review := range burst {
+				if review.DatePublished[:len("2026-01-30")] != "2026-01-30" {
+					t.Errorf("review of %s is in a burst", review.DatePublished)
+				}
+			}
+		})
+	}
+}
+
+func TestBurstReviewsSteadyPlatform(t *testing.T) {
+	platformID := uuid.New()
+
+	days := map[string][]*models.Review{}
+	for day := 1; day <= 30; day++ {
+		date := fmt.Sprintf("2026-01-%02d", day)
+		for range 6 {
+			days[date] = append(days[date], testReview(platformID, day, 5, "", ""))
+		}
+	}
+
+	if burst := burstReviews(days); len(burst) != 0 {
+		t.Errorf("got %d burst reviews on a steady platform", len(burst))
+	}
+}
+
+func TestIsShortGeneric(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "Great product!", want: true},
+		{text: "Very good value for money", want: true},
+		{text: "Great zipper", want: true},
+		{text: "Great zipper, broke", want: false},
+		{text: "Zipper broke, seams tore", want: false},
+		{text: "Great great great great great great", want: false},
+		{text: "The zipper broke after a week of use", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := isShortGeneric(reviewWords(test.text)); got != test.want {
+				t.Errorf("isShortGeneric = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyLLMScore(t *testing.T) {
+	llmScore := func(score float64) *float64 { return &score }
+
+	tests := []struct {
+		name       string
+		score      float64
+		llmScore   *float64
+		want       float64
+		wantReason bool
+	}{
+		{name: "not judged", score: 0.6, want: 0.6},
+		{name: "suspicious", score: 0.6, llmScore: llmScore(0.9), want: 0.75, wantReason: true},
+		{name: "at the reason score", score: 0, llmScore: llmScore(suspicionLLMReasonScore), want: suspicionLLMReasonScore / 2, wantReason: true},
+		{name: "genuine", score: 0.6, llmScore: llmScore(0), want: 0.3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score := &models.ReviewSuspicion{Score: test.score, Reasons: []string{}, LLMScore: test.llmScore}
+			applyLLMScore(score)
+
+			if score.Score != test.want {
+				t.Errorf("score = %v, want %v", score.Score, test.want)
+			}
+			if got := slices.Contains(score.Reasons, string(consts.SuspicionReasonLLMJudgment)); got != test.wantReason {
+				t.Errorf("LLM judgment reason = %v, want %v", got, test.wantReason)
+			}
+		})
+	}
+}
+
+func TestScoreReviewsKeepsLLMScore(t *testing.T) {
+	llmScore := 0.8
+	review := testReview(uuid.New(), 3, 5, "Ann", "Great product!")
+	review.SuspicionLLMScore = &llmScore
+
+	score := scoreOf(scoreReviews([]*models.Review{review}), review)
+
+	if want := (suspicionWeights[consts.SuspicionReasonShortGeneric] + llmScore) / 2; score.Score != want {
+		t.Errorf("score = %v, want %v", score.Score, want)
+	}
+	if score.LLMScore == nil || *score.LLMScore != llmScore {
+		t.Errorf("LLM score = %v, want %v", score.LLMScore, llmScore)
+	}
+}
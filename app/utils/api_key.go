@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// APIKeyPrefix marks personal API keys so they can be told apart from JWTs
+const APIKeyPrefix = "rk_"
+
+// apiKeyDisplayLength is how much of a key is stored in clear to identify it
+const apiKeyDisplayLength = 10
+
+// GenerateAPIKey returns a new API key, the prefix shown to identify it and
+// the hash to store. The key itself is never stored.
+func GenerateAPIKey() (key, displayPrefix, hash string, err error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", "", err
+	}
+
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(randomBytes)
+	return key, key[:apiKeyDisplayLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes a key for storage and lookup. Keys carry 256 bits of
+// randomness, so a plain SHA-256 is enough.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
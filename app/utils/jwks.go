@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	jwksCacheTTL = time.Hour
+	// jwksMinRefreshInterval limits refreshes, so tokens with made up kids
+	// cannot make us hammer the endpoint. jwksRetryInterval applies instead
+	// while no key set was ever fetched.
+	jwksMinRefreshInterval = time.Minute
+	jwksRetryInterval      = 5 * time.Second
+	jwksFetchTimeout       = 10 * time.Second
+)
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// JWKSClient fetches RSA signing keys from a JWKS endpoint and caches them.
+// The set is refreshed after jwksCacheTTL, or earlier when a token names a
+// kid we don't know, e.g. right after the issuer rotates keys. Refreshes run
+// without holding the lock, so cached keys are served while one is under
+// way, and concurrent callers share a single fetch.
+type JWKSClient struct {
+	url        string
+	httpClient *http.Client
+
+	mutex       sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// refreshing is closed when the refresh in flight finishes, nil otherwise
+	refreshing chan struct{}
+	refreshErr error
+}
+
+func NewJWKSClient(url string) *JWKSClient {
+	return &JWKSClient{
+		url:        url,
+		httpClient: &http.Client{Timeout: jwksFetchTimeout},
+		keys:       map[string]*rsa.PublicKey{},
+	}
+}
+
+// Key returns the public key for kid
+func (j *JWKSClient) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mutex.Lock()
+	key, ok := j.keys[kid]
+	if ok && time.Since(j.fetchedAt) <= jwksCacheTTL {
+		j.mutex.Unlock()
+		return key, nil
+	}
+
+	refreshing := j.startRefresh()
+	refreshErr := j.refreshErr
+	j.mutex.Unlock()
+
+	// Keep serving a stale key while the set is refreshed, or if the
+	// endpoint is unavailable
+	if ok {
+		return key, nil
+	}
+	if refreshing == nil && refreshErr != nil {
+		return nil, refreshErr
+	}
+	if refreshing == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	select {
+	case <-refreshing:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	j.mutex.Lock()
+	key, ok = j.keys[kid]
+	refreshErr = j.refreshErr
+	j.mutex.Unlock()
+
+	if !ok && refreshErr != nil {
+		return nil, refreshErr
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+// startRefresh returns the channel of the refresh in flight, starting one if
+// the refresh interval allows, or nil. The caller holds the mutex.
+func (j *JWKSClient) startRefresh() chan struct{} {
+	if j.refreshing != nil {
+		return j.refreshing
+	}
+
+	interval := jwksMinRefreshInterval
+	if j.fetchedAt.IsZero() {
+		interval = jwksRetryInterval
+	}
+	if time.Since(j.lastAttempt) < interval {
+		return nil
+	}
+
+	j.lastAttempt = time.Now()
+	refreshing := make(chan struct{})
+	j.refreshing = refreshing
+
+	// Not bound to the caller's context, other callers wait on the result
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+		defer cancel()
+
+		keys, err := j.fetch(ctx)
+
+		j.mutex.Lock()
+		j.refreshErr = err
+		if err == nil {
+			j.keys = keys
+			j.fetchedAt = time.Now()
+		}
+		j.refreshing = nil
+		j.mutex.Unlock()
+
+		close(refreshing)
+	}()
+
+	return refreshing
+}
+
+// fetch downloads the key set
+func (j *JWKSClient) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating jwks request: %w", err)
+	}
+
+	resp, err := j.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned status code: %d", resp.StatusCode)
+	}
+
+	var keySet jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keySet); err != nil {
+		return nil, fmt.Errorf("error decoding jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		key, err := parseRSAJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing jwk %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func parseRSAJWK(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/review-aggregator/review-api/app/internal/testutil"
+)
+
+func newJWKSStub(t *testing.T) *testutil.JWKSStub {
+	t.Helper()
+
+	stub, err := testutil.NewJWKSStub()
+	if err != nil {
+		t.Fatalf("NewJWKSStub: %v", err)
+	}
+	t.Cleanup(stub.Close)
+
+	return stub
+}
+
+func TestJWKSClientKeyLookup(t *testing.T) {
+	stub := newJWKSStub(t)
+	client := NewJWKSClient(stub.URL())
+
+	key, err := client.Key(context.Background(), stub.Kid())
+	if err != nil || key == nil {
+		t.Fatalf("Key(%q) = %v, %v", stub.Kid(), key, err)
+	}
+
+	// Known keys are served from the cache
+	if _, err := client.Key(context.Background(), stub.Kid()); err != nil {
+		t.Fatalf("Key(%q) from cache: %v", stub.Kid(), err)
+	}
+	if stub.Fetches() != 1 {
+		t.Errorf("fetched the key set %d times, want 1", stub.Fetches())
+	}
+
+	if _, err := client.Key(context.Background(), "no-such-key"); err == nil {
+		t.Error("Key returned a key for an unknown kid")
+	}
+}
+
+func TestJWKSClientRefreshesOnUnknownKid(t *testing.T) {
+	stub := newJWKSStub(t)
+	client := NewJWKSClient(stub.URL())
+
+	if _, err := client.Key(context.Background(), stub.Kid()); err != nil {
+		t.Fatalf("Key(%q): %v", stub.Kid(), err)
+	}
+
+	if err := stub.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	// Within jwksMinRefreshInterval of the last fetch an unknown kid does not
+	// hit the endpoint again
+	if _, err := client.Key(context.Background(), stub.Kid()); err == nil {
+		t.Fatal("Key found the rotated key without refreshing")
+	}
+	if stub.Fetches() != 1 {
+		t.Fatalf("fetched the key set %d times within the refresh interval, want 1", stub.Fetches())
+	}
+
+	client.lastAttempt = time.Now().Add(-2 * jwksMinRefreshInterval)
+
+	if _, err := client.Key(context.Background(), stub.Kid()); err != nil {
+		t.Fatalf("Key(%q) after rotation: %v", stub.Kid(), err)
+	}
+	if stub.Fetches() != 2 {
+		t.Errorf("fetched the key set %d times, want 2", stub.Fetches())
+	}
+}
+
+func TestJWKSClientKeepsCachedKeysWhenEndpointFails(t *testing.T) {
+	stub := newJWKSStub(t)
+	client := NewJWKSClient(stub.URL())
+
+	kid := stub.Kid()
+	if _, err := client.Key(context.Background(), kid); err != nil {
+		t.Fatalf("Key(%q): %v", kid, err)
+	}
+
+	stub.Close()
+	client.fetchedAt = time.Now().Add(-2 * jwksCacheTTL)
+	client.lastAttempt = time.Now().Add(-2 * jwksMinRefreshInterval)
+
+	if _, err := client.Key(context.Background(), kid); err != nil {
+		t.Errorf("Key(%q) with the endpoint down: %v", kid, err)
+	}
+}
+
+func TestJWKSClientRefreshDoesNotBlockCachedKeys(t *testing.T) {
+	stub := newJWKSStub(t)
+	client := NewJWKSClient(stub.URL())
+
+	kid := stub.Kid()
+	if _, err := client.Key(context.Background(), kid); err != nil {
+		t.Fatalf("Key(%q): %v", kid, err)
+	}
+
+	if err := stub.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	release := stub.Hold()
+	defer release()
+	client.lastAttempt = time.Now().Add(-2 * jwksMinRefreshInterval)
+
+	// Several requests with the new kid wait on one refresh of the hanging endpoint
+	const waiting = 5
+	errs := make(chan error, waiting)
+	for i := 0; i < waiting; i++ {
+		go func() {
+			_, err := client.Key(context.Background(), stub.Kid())
+			errs <- err
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for stub.Fetches() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Meanwhile the cached key is still served straight away
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Key(context.Background(), kid)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Key(%q) during the refresh: %v", kid, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Key for a cached kid blocked on the refresh")
+	}
+
+	// Callers can give up on the refresh
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.Key(ctx, stub.Kid()); err != context.DeadlineExceeded {
+		t.Errorf("Key with an expiring context = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	release()
+	for i := 0; i < waiting; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Key(%q) after the refresh: %v", stub.Kid(), err)
+		}
+	}
+	if stub.Fetches() != 2 {
+		t.Errorf("fetched the key set %d times, want 2", stub.Fetches())
+	}
+}
+
+func TestJWKSClientLimitsUnknownKidRefreshes(t *testing.T) {
+	stub := newJWKSStub(t)
+	client := NewJWKSClient(stub.URL())
+
+	if _, err := client.Key(context.Background(), stub.Kid()); err != nil {
+		t.Fatalf("Key(%q): %v", stub.Kid(), err)
+	}
+	client.lastAttempt = time.Now().Add(-2 * jwksMinRefreshInterval)
+
+	for i := 0; i < 20; i++ {
+		client.Key(context.Background(), "made-up-kid")
+	}
+	if stub.Fetches() != 2 {
+		t.Errorf("fetched the key set %d times for made up kids, want 2", stub.Fetches())
+	}
+}
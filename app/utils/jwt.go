@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
@@ -12,12 +13,13 @@ type AuthTokenClaims struct {
 	Email  string    `json:"email"`
 }
 
-// GenerateAuthToken generates auth token for a given secret
-func GenerateAuthToken(secret string, claimsToAdd AuthTokenClaims) (string, error) {
+// GenerateAuthToken generates auth token for a given secret that expires after ttl
+func GenerateAuthToken(secret string, claimsToAdd AuthTokenClaims, ttl time.Duration) (string, error) {
 	var secretKeyInBytes = []byte(secret)
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = 0
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(ttl).Unix()
 	claims["user_id"] = claimsToAdd.UserID
 	claims["email"] = claimsToAdd.Email
 
@@ -29,19 +31,25 @@ func GenerateAuthToken(secret string, claimsToAdd AuthTokenClaims) (string, erro
 	return tokenString, nil
 }
 
-// ValidateJWT validates the given JWT token and returns the parsed token
-func ValidateJWT(tokenString string) (*jwt.Token, error) {
+// ValidateJWT verifies the token's HS256 signature with secret and its expiry,
+// and returns the parsed token
+func ValidateJWT(secret string, tokenString string) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Ensure that the token's signing method is what you expect
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return token, nil
+		return []byte(secret), nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("token has no valid expiry")
+	}
+
 	return token, nil
 }
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	HeaderSignature          = "X-Signature"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderSignatureNonce     = "X-Signature-Nonce"
+)
+
+// SignRequest returns the hex HMAC-SHA256 of a service-to-service request.
+// timestamp is in unix seconds and requestURI includes the query string.
+func SignRequest(secret, timestamp, nonce, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{timestamp, nonce, method, requestURI}, "\n") + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequestSignature reports whether signature matches the request in constant time
+func VerifyRequestSignature(secret, signature, timestamp, nonce, method, requestURI string, body []byte) bool {
+	expected := SignRequest(secret, timestamp, nonce, method, requestURI, body)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
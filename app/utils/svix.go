@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const svixTimestampTolerance = 5 * time.Minute
+
+// VerifySvixWebhook checks the Svix signature headers Clerk sends with each
+// webhook. secret is the endpoint's signing secret, including its "whsec_" prefix.
+func VerifySvixWebhook(secret string, header http.Header, body []byte) error {
+	msgID := header.Get("svix-id")
+	timestamp := header.Get("svix-timestamp")
+	signatures := header.Get("svix-signature")
+	if msgID == "" || timestamp == "" || signatures == "" {
+		return errors.New("missing svix headers")
+	}
+
+	unixSeconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid svix timestamp")
+	}
+
+	if age := time.Since(time.Unix(unixSeconds, 0)); age > svixTimestampTolerance || age < -svixTimestampTolerance {
+		return errors.New("svix timestamp outside allowed window")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return errors.New("invalid webhook secret")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msgID + "." + timestamp + "."))
+	mac.Write(body)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	// The header holds space separated "version,signature" pairs, one per active secret
+	for _, versionedSignature := range strings.Fields(signatures) {
+		version, signature, found := strings.Cut(versionedSignature, ",")
+		if found && version == "v1" && hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return errors.New("no matching svix signature")
+}
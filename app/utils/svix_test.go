@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testSvixKey    = "test-webhook-signing-key"
+	testSvixSecret = "whsec_dGVzdC13ZWJob29rLXNpZ25pbmcta2V5" // base64 of testSvixKey
+)
+
+func svixSignature(key, msgID, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msgID + "." + timestamp + "."))
+	mac.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySvixWebhook(t *testing.T) {
+	body := []byte(`{"type": "user.created", "data": {"id": "user_123"}}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-svixTimestampTolerance-time.Minute).Unix(), 10)
+	valid := svixSignature(testSvixKey, "msg_1", now, body)
+
+	tests := []struct {
+		name      string
+		msgID     string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid signature", "msg_1", now, valid, body, false},
+		{"one of several signatures", "msg_1", now, svixSignature("rotated-key", "msg_1", now, body) + " " + valid, body, false},
+		{"other versions are ignored", "msg_1", now, "v1a," + valid[len("v1,"):], body, true},
+		{"signature of another key", "msg_1", now, svixSignature("other-key", "msg_1", now, body), body, true},
+		{"tampered body", "msg_1", now, valid, []byte(`{"type": "user.deleted"}`), true},
+		{"other message id", "msg_2", now, valid, body, true},
+		{"expired timestamp", "msg_1", stale, svixSignature(testSvixKey, "msg_1", stale, body), body, true},
+		{"invalid timestamp", "msg_1", "yesterday", valid, body, true},
+		{"missing signature", "msg_1", now, "", body, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("svix-id", tt.msgID)
+			header.Set("svix-timestamp", tt.timestamp)
+			header.Set("svix-signature", tt.signature)
+
+			err := VerifySvixWebhook(testSvixSecret, header, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySvixWebhook error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/services"
+)
+
+// Example is one labeled line of a dataset
+type Example struct {
+	ID                 string            `json:"id"`
+	ProductDescription string            `json:"product_description"`
+	Categories         []ExampleCategory `json:"categories"`
+	Reviews            []ExampleReview   `json:"reviews"`
+	Expected           Expected          `json:"expected"`
+}
+
+// ExampleCategory is a sentiment category, DefaultSentimentCategories are
+// used for examples without any
+type ExampleCategory struct {
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	ExamplePhrases []string `json:"example_phrases"`
+}
+
+type ExampleReview struct {
+	Rating   float64 `json:"rating"`
+	Headline string  `json:"headline"`
+	Body     string  `json:"body"`
+}
+
+// Expected holds the labels. Either part may be left out to skip the
+// corresponding metrics for the example.
+type Expected struct {
+	Sentiment     []models.SentimentCount `json:"sentiment"`
+	KeyHighlights []string                `json:"key_highlights"`
+	PainPoints    []string                `json:"pain_points"`
+}
+
+// loadDataset reads a JSONL file with one Example per line, blank lines and
+// lines starting with "#" are skipped
+func loadDataset(path string) ([]*Example, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var examples []*Example
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var example Example
+		if err := json.Unmarshal([]byte(line), &example); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if len(example.Reviews) == 0 {
+			return nil, fmt.Errorf("line %d: example has no reviews", lineNumber)
+		}
+		if example.ID == "" {
+			example.ID = fmt.Sprintf("line-%d", lineNumber)
+		}
+
+		examples = append(examples, &example)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return examples, nil
+}
+
+// reviews converts the example's reviews into the models the pipelines take
+func (e *Example) reviews() []*models.Review {
+	reviews := make([]*models.Review, 0, len(e.Reviews))
+	for _, review := range e.Reviews {
+		reviews = append(reviews, &models.Review{
+			ID:          uuid.New(),
+			RatingValue: review.Rating,
+			Headline:    review.Headline,
+			ReviewBody:  review.Body,
+		})
+	}
+
+	return reviews
+}
+
+// sentimentCategories returns the example's categories in the form the
+// sentiment pipeline takes
+func (e *Example) sentimentCategories() []*models.SentimentCategory {
+	var categories []*models.SentimentCategory
+	for position, category := range e.Categories {
+		examplePhrases := category.ExamplePhrases
+		if examplePhrases == nil {
+			examplePhrases = []string{}
+		}
+
+		categories = append(categories, &models.SentimentCategory{
+			Name:           category.Name,
+			Description:    category.Description,
+			ExamplePhrases: examplePhrases,
+			Position:       position,
+		})
+	}
+
+	if len(categories) == 0 {
+		for position, name := range services.DefaultSentimentCategories {
+			categories = append(categories, &models.SentimentCategory{
+				Name:           name,
+				ExamplePhrases: []string{},
+				Position:       position,
+			})
+		}
+	}
+
+	return categories
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fakeTransport answers LLM requests in process with a keyword heuristic so
+// the harness, prompts and parsing can be exercised without a model. Its
+// scores are a baseline, not a measure of prompt quality.
+type fakeTransport struct{}
+
+var (
+	fakeReviewLine   = regexp.MustCompile(`(?m)^- Rating: ([0-9.]+) \| (.*)$`)
+	fakeCategoryLine = regexp.MustCompile(`"category": "((?:[^"\\]|\\.)*)"`)
+	fakeDetailLine   = regexp.MustCompile(`(?m)^- ([^:\n(]+?)(?::|\s\(e\.g\.|$)(.*)$`)
+	fakePhrase       = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+type fakeReview struct {
+	rating float64
+	body   string
+}
+
+func (fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var request struct {
+		Messages []map[string]string `json:"messages"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return nil, fmt.Errorf("error decoding fake LLM request: %w", err)
+	}
+	req.Body.Close()
+
+	var system, user string
+	for _, message := range request.Messages {
+		switch message["role"] {
+		case "system":
+			system = message["content"]
+		case "user":
+			user = message["content"]
+		}
+	}
+
+	var reviews []fakeReview
+	for _, match := range fakeReviewLine.FindAllStringSubmatch(user, -1) {
+		rating, _ := strconv.ParseFloat(match[1], 64)
+		reviews = append(reviews, fakeReview{rating: rating, body: match[2]})
+	}
+
+	var content interface{}
+	switch {
+	case strings.Contains(system, "sentiment analyzer"):
+		content = fakeSentiment(system, reviews)
+	case strings.Contains(system, "review analyzer"):
+		content = fakeSummary(reviews)
+	default:
+		return nil, fmt.Errorf("fake LLM does not know this prompt")
+	}
+
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]string{"role": "assistant", "content": string(contentJSON)}},
+		},
+		"usage": map[string]int{
+			"prompt_tokens":     len(strings.Fields(system)) + len(strings.Fields(user)),
+			"completion_tokens": len(strings.Fields(string(contentJSON))),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// fakeSentiment counts a review as positive or negative for a category when
+// it mentions one of the category's words or example phrases, depending on
+// its rating
+func fakeSentiment(system string, reviews []fakeReview) []map[string]interface{} {
+	keywords := fakeCategoryKeywords(system)
+
+	var categories []map[string]interface{}
+	for _, match := range fakeCategoryLine.FindAllStringSubmatch(system, -1) {
+		category := match[1]
+		positive, negative, noOpinion := 0, 0, 0
+		for _, review := range reviews {
+			switch {
+			case !fakeMentions(review.body, keywords[category]):
+				noOpinion++
+			case review.rating >= 4:
+				positive++
+			case review.rating <= 2:
+				negative++
+			default:
+				noOpinion++
+			}
+		}
+
+		categories = append(categories, map[string]interface{}{
+			"category":   category,
+			"positive":   positive,
+			"negative":   negative,
+			"no_opinion": noOpinion,
+		})
+	}
+
+	return categories
+}
+
+// fakeCategoryKeywords returns the lowercase words of each category's name
+// and its example phrases from the category list of the system prompt
+func fakeCategoryKeywords(system string) map[string][]string {
+	keywords := make(map[string][]string)
+	for _, match := range fakeCategoryLine.FindAllStringSubmatch(system, -1) {
+		keywords[match[1]] = contentWords(match[1])
+	}
+
+	for _, match := range fakeDetailLine.FindAllStringSubmatch(system, -1) {
+		name := strings.TrimSpace(match[1])
+		if _, ok := keywords[name]; !ok {
+			continue
+		}
+		for _, phrase := range fakePhrase.FindAllStringSubmatch(match[2], -1) {
+			keywords[name] = append(keywords[name], strings.ToLower(phrase[1]))
+		}
+	}
+
+	return keywords
+}
+
+func fakeMentions(body string, keywords []string) bool {
+	body = strings.ToLower(body)
+	for _, keyword := range keywords {
+		if strings.Contains(body, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fakeSummary uses the first sentence of the best rated reviews as key
+// highlights and of the worst rated ones as pain points
+func fakeSummary(reviews []fakeReview) map[string]interface{} {
+	highlights, painPoints := []string{}, []string{}
+	var total float64
+	for _, review := range reviews {
+		total += review.rating
+		sentence := firstSentence(review.body)
+		switch {
+		case review.rating >= 4 && len(highlights) < 5:
+			highlights = append(highlights, sentence)
+		case review.rating <= 2 && len(painPoints) < 5:
+			painPoints = append(painPoints, sentence)
+		}
+	}
+
+	overall := "Customers have mixed feelings."
+	if len(reviews) > 0 {
+		switch average := total / float64(len(reviews)); {
+		case average >= 4:
+			overall = "Customers are mostly satisfied."
+		case average <= 2:
+			overall = "Customers are mostly dissatisfied."
+		}
+	}
+
+	return map[string]interface{}{
+		"key_highlights":    highlights,
+		"pain_points":       painPoints,
+		"overall_sentiment": overall,
+	}
+}
+
+func firstSentence(text string) string {
+	if i := strings.IndexAny(text, ".!?"); i >= 0 {
+		return strings.TrimSpace(text[:i+1])
+	}
+
+	return strings.TrimSpace(text)
+}
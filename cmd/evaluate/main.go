@@ -0,0 +1,194 @@
+// Command evaluate runs the summary and sentiment pipelines over a labeled
+// JSONL dataset and reports accuracy, JSON validity, category F1 and latency.
+//
+//	go run ./cmd/evaluate -dataset cmd/evaluate/testdata/sample.jsonl -backend fake
+//
+// The fake backend answers in process with a keyword heuristic, ollama talks
+// to a local model, groq to the hosted API (GROQ_API_KEY_SUMMARY and
+// GROQ_API_KEY_SENTIMENT) and replay serves recorded fixtures.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/review-aggregator/review-api/app/config"
+	"github.com/review-aggregator/review-api/app/models"
+	"github.com/review-aggregator/review-api/app/prompts"
+	"github.com/review-aggregator/review-api/app/services"
+)
+
+const (
+	backendFake   = "fake"
+	backendOllama = "ollama"
+	backendGroq   = "groq"
+	backendReplay = "replay"
+)
+
+// Report is the outcome of an evaluation run
+type Report struct {
+	Dataset   string          `json:"dataset"`
+	Backend   string          `json:"backend"`
+	Model     string          `json:"model"`
+	Examples  int             `json:"examples"`
+	Summary   SummaryReport   `json:"summary"`
+	Sentiment SentimentReport `json:"sentiment"`
+}
+
+func main() {
+	dataset := flag.String("dataset", "cmd/evaluate/testdata/sample.jsonl", "labeled JSONL dataset")
+	backend := flag.String("backend", backendFake, "LLM backend: fake, ollama, groq or replay")
+	model := flag.String("model", "", "model to use instead of the backend's default")
+	promptVersions := flag.String("prompt-versions", "", "prompt versions to evaluate, e.g. summary=v1,sentiment=v2")
+	fixturesDir := flag.String("fixtures-dir", "testdata/fixtures", "fixtures served by the replay backend")
+	jsonOutput := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	// API keys may come from .env like for the server
+	_ = godotenv.Load()
+
+	if err := configure(*backend, *model, *promptVersions, *fixturesDir); err != nil {
+		fmt.Fprintln(os.Stderr, "evaluate:", err)
+		os.Exit(2)
+	}
+
+	examples, err := loadDataset(*dataset)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "evaluate: error loading dataset:", err)
+		os.Exit(1)
+	}
+
+	report := evaluate(context.Background(), examples)
+	report.Dataset = *dataset
+	report.Backend = *backend
+	report.Model = services.LLMModel(services.ConfiguredLLMProvider())
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		return
+	}
+
+	printReport(report)
+}
+
+// configure points the services at the backend. Usage is not recorded since
+// there is no database.
+func configure(backend, model, promptVersions, fixturesDir string) error {
+	config.Config.LLMModel = model
+	config.Config.LLMProvider = string(services.ProviderGroq)
+
+	switch backend {
+	case backendFake:
+		services.SetLLMClient(&http.Client{Transport: fakeTransport{}})
+		if model == "" {
+			config.Config.LLMModel = backendFake
+		}
+	case backendOllama:
+		config.Config.LLMProvider = string(services.ProviderOllama)
+		services.SetLLMClient(&http.Client{Timeout: 5 * time.Minute})
+	case backendGroq:
+		services.SetLLMClient(&http.Client{Timeout: 2 * time.Minute})
+	case backendReplay:
+		services.SetLLMClient(&http.Client{Transport: services.NewReplayTransport(fixturesDir)})
+	default:
+		return fmt.Errorf("unknown backend %q", backend)
+	}
+
+	services.SetLLMUsageRecorder(func(context.Context, *models.LLMUsage) error { return nil })
+
+	versions := make(map[string]string)
+	for _, pin := range strings.Split(promptVersions, ",") {
+		if pin = strings.TrimSpace(pin); pin == "" {
+			continue
+		}
+
+		name, version, ok := strings.Cut(pin, "=")
+		if !ok {
+			return fmt.Errorf("invalid prompt version %q, expected name=version", pin)
+		}
+		if _, err := prompts.GetVersion(name, version); err != nil {
+			return err
+		}
+		versions[name] = version
+	}
+	config.Config.PromptVersions = versions
+
+	return nil
+}
+
+func evaluate(ctx context.Context, examples []*Example) Report {
+	report := Report{Examples: len(examples)}
+
+	if prompt, err := prompts.Get(prompts.Summary); err == nil {
+		report.Summary.Prompt = prompt.ID()
+	}
+	if prompt, err := prompts.Get(prompts.Sentiment); err == nil {
+		report.Sentiment.Prompt = prompt.ID()
+	}
+
+	for _, example := range examples {
+		reviews := example.reviews()
+
+		start := time.Now()
+		stats, err := services.GetProductStats(ctx, reviews, example.ProductDescription)
+		report.Summary.record(time.Since(start), err, errors.Is(err, services.ErrInvalidLLMOutput))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: summary: %v\n", example.ID, err)
+		}
+		if stats != nil {
+			report.Summary.score(example.Expected, stats.KeyHighlights, stats.PainPoints)
+		} else {
+			report.Summary.score(example.Expected, nil, nil)
+		}
+
+		start = time.Now()
+		sentiment, _, err := services.GetSentimentAnalysis(ctx, reviews, example.ProductDescription, example.sentimentCategories())
+		report.Sentiment.record(time.Since(start), err, errors.Is(err, services.ErrInvalidLLMOutput))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: sentiment: %v\n", example.ID, err)
+		}
+
+		report.Sentiment.score(example.Expected.Sentiment, sentiment)
+	}
+
+	report.Summary.finish()
+	report.Sentiment.finish()
+
+	return report
+}
+
+func printReport(report Report) {
+	fmt.Printf("\nDataset: %s (%d examples)\nBackend: %s, model %s\n\n", report.Dataset, report.Examples, report.Backend, report.Model)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "pipeline\tprompt\truns\terrors\tjson valid\tmean ms\tp50 ms\tp95 ms")
+	for _, pipeline := range []struct {
+		name   string
+		report PipelineReport
+	}{
+		{"summary", report.Summary.PipelineReport},
+		{"sentiment", report.Sentiment.PipelineReport},
+	} {
+		r := pipeline.report
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f%%\t%.1f\t%.1f\t%.1f\n",
+			pipeline.name, r.Prompt, r.Runs, r.Errors, 100*r.JSONValidRate, r.LatencyMeanMS, r.LatencyP50MS, r.LatencyP95MS)
+	}
+	w.Flush()
+
+	fmt.Printf("\nSentiment accuracy:  %.1f%%\n", 100*report.Sentiment.Accuracy)
+	fmt.Printf("Category F1:         %.3f (precision %.3f, recall %.3f)\n", report.Sentiment.CategoryF1, report.Sentiment.CategoryPrecision, report.Sentiment.CategoryRecall)
+	fmt.Printf("Count MAE:           %.2f\n", report.Sentiment.CountMAE)
+	fmt.Printf("Highlight recall:    %.1f%%\n", 100*report.Summary.HighlightRecall)
+	fmt.Printf("Pain point recall:   %.1f%%\n", 100*report.Summary.PainPointRecall)
+}
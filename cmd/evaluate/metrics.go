@@ -0,0 +1,258 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/review-aggregator/review-api/app/models"
+)
+
+// itemMatchThreshold is the share of an expected item's words a produced
+// highlight or pain point must contain to count as matching it
+const itemMatchThreshold = 0.5
+
+// Polarity labels a category's counts for accuracy, see polarity
+const (
+	polarityPositive = "positive"
+	polarityNegative = "negative"
+	polarityMixed    = "mixed"
+	polarityNone     = "none"
+)
+
+// PipelineReport holds the metrics of one pipeline over the dataset
+type PipelineReport struct {
+	Prompt string `json:"prompt"`
+	Runs   int    `json:"runs"`
+	// Errors are failed calls, InvalidOutputs the subset where the model
+	// answered with something other than the requested JSON
+	Errors         int     `json:"errors"`
+	InvalidOutputs int     `json:"invalid_outputs"`
+	JSONValidRate  float64 `json:"json_valid_rate"`
+	LatencyMeanMS  float64 `json:"latency_mean_ms"`
+	LatencyP50MS   float64 `json:"latency_p50_ms"`
+	LatencyP95MS   float64 `json:"latency_p95_ms"`
+
+	latencies []time.Duration
+}
+
+func (r *PipelineReport) record(latency time.Duration, err error, invalidOutput bool) {
+	r.Runs++
+	r.latencies = append(r.latencies, latency)
+	if err != nil {
+		r.Errors++
+	}
+	if invalidOutput {
+		r.InvalidOutputs++
+	}
+}
+
+func (r *PipelineReport) finish() {
+	if r.Runs == 0 {
+		return
+	}
+
+	// Calls that failed before the model answered say nothing about validity
+	answered := r.Runs - (r.Errors - r.InvalidOutputs)
+	if answered > 0 {
+		r.JSONValidRate = float64(answered-r.InvalidOutputs) / float64(answered)
+	}
+
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	r.LatencyMeanMS = milliseconds(total / time.Duration(len(sorted)))
+	r.LatencyP50MS = milliseconds(percentile(sorted, 0.50))
+	r.LatencyP95MS = milliseconds(percentile(sorted, 0.95))
+}
+
+// SentimentReport adds the label metrics of the sentiment pipeline
+type SentimentReport struct {
+	PipelineReport
+	// Accuracy is the share of labeled categories whose polarity matches
+	Accuracy float64 `json:"accuracy"`
+	// CategoryPrecision, CategoryRecall and CategoryF1 score which categories
+	// the reviews were found to have an opinion on, micro averaged
+	CategoryPrecision float64 `json:"category_precision"`
+	CategoryRecall    float64 `json:"category_recall"`
+	CategoryF1        float64 `json:"category_f1"`
+	// CountMAE is the mean absolute error of the positive, negative and
+	// no_opinion counts
+	CountMAE float64 `json:"count_mae"`
+
+	labeled, correct            int
+	truePos, falsePos, falseNeg int
+	countErrors, countValues    int
+}
+
+// score compares the produced counts of one example with its labels
+func (r *SentimentReport) score(expected []models.SentimentCount, produced models.SentimentCounts) {
+	producedByName := make(map[string]models.SentimentCount, len(produced))
+	for _, category := range produced {
+		producedByName[normalize(category.Category)] = category
+	}
+
+	for _, want := range expected {
+		got, ok := producedByName[normalize(want.Category)]
+
+		r.labeled++
+		if ok && polarity(got) == polarity(want) {
+			r.correct++
+		}
+
+		wantMentioned := want.Positive+want.Negative > 0
+		gotMentioned := ok && got.Positive+got.Negative > 0
+		switch {
+		case wantMentioned && gotMentioned:
+			r.truePos++
+		case gotMentioned:
+			r.falsePos++
+		case wantMentioned:
+			r.falseNeg++
+		}
+
+		r.countErrors += abs(got.Positive-want.Positive) + abs(got.Negative-want.Negative) + abs(got.NoOpinion-want.NoOpinion)
+		r.countValues += 3
+	}
+}
+
+func (r *SentimentReport) finish() {
+	r.PipelineReport.finish()
+
+	r.Accuracy = ratio(r.correct, r.labeled)
+	r.CategoryPrecision = ratio(r.truePos, r.truePos+r.falsePos)
+	r.CategoryRecall = ratio(r.truePos, r.truePos+r.falseNeg)
+	if r.CategoryPrecision+r.CategoryRecall > 0 {
+		r.CategoryF1 = 2 * r.CategoryPrecision * r.CategoryRecall / (r.CategoryPrecision + r.CategoryRecall)
+	}
+	r.CountMAE = ratio(r.countErrors, r.countValues)
+}
+
+// SummaryReport adds the label metrics of the summary pipeline
+type SummaryReport struct {
+	PipelineReport
+	// HighlightRecall and PainPointRecall are the shares of labeled items
+	// matched by a produced one
+	HighlightRecall float64 `json:"highlight_recall"`
+	PainPointRecall float64 `json:"pain_point_recall"`
+
+	highlights, matchedHighlights int
+	painPoints, matchedPainPoints int
+}
+
+func (r *SummaryReport) score(expected Expected, keyHighlights, painPoints []string) {
+	r.highlights += len(expected.KeyHighlights)
+	r.matchedHighlights += countMatched(expected.KeyHighlights, keyHighlights)
+	r.painPoints += len(expected.PainPoints)
+	r.matchedPainPoints += countMatched(expected.PainPoints, painPoints)
+}
+
+func (r *SummaryReport) finish() {
+	r.PipelineReport.finish()
+
+	r.HighlightRecall = ratio(r.matchedHighlights, r.highlights)
+	r.PainPointRecall = ratio(r.matchedPainPoints, r.painPoints)
+}
+
+// polarity labels counts by which opinion outweighs the other
+func polarity(category models.SentimentCount) string {
+	switch {
+	case category.Positive > category.Negative:
+		return polarityPositive
+	case category.Negative > category.Positive:
+		return polarityNegative
+	case category.Positive > 0:
+		return polarityMixed
+	default:
+		return polarityNone
+	}
+}
+
+// countMatched returns how many of the expected items a produced item covers
+func countMatched(expected, produced []string) int {
+	matched := 0
+	for _, want := range expected {
+		wantWords := contentWords(want)
+		if len(wantWords) == 0 {
+			continue
+		}
+
+		for _, got := range produced {
+			gotWords := make(map[string]bool)
+			for _, word := range contentWords(got) {
+				gotWords[word] = true
+			}
+
+			shared := 0
+			for _, word := range wantWords {
+				if gotWords[word] {
+					shared++
+				}
+			}
+
+			if float64(shared)/float64(len(wantWords)) >= itemMatchThreshold {
+				matched++
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// contentWords returns the lowercase words of text that are long enough to carry
+// meaning
+func contentWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if len(word) > 3 {
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+
+	return sorted[index]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+
+	return float64(numerator) / float64(denominator)
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
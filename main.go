@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -22,8 +23,20 @@ func main() {
 		panic(err)
 	}
 
+	// Pick up backfills interrupted by the last shutdown
+	if err := services.ResumeBackfillJobs(context.Background()); err != nil {
+		log.Printf("Failed to resume backfill jobs: %v", err)
+	}
+
+	// Daily anomaly detection and suspicion scoring for every product
 	c := cron.New()
-	c.AddFunc("@daily", func() { services.CronRunScraperAndGetStats() })
+	c.AddFunc("@daily", func() {
+		if err := services.CronDetectReviewIssues(); err != nil {
+			log.Printf("Failed to detect review issues: %v", err)
+		}
+	})
+	c.Start()
+	defer c.Stop()
 
 	// Set up the router
 	r := router.SetupRouter()
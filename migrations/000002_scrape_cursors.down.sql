@@ -0,0 +1 @@
+DROP TABLE IF EXISTS scrape_cursors CASCADE;
@@ -0,0 +1,9 @@
+CREATE TABLE scrape_cursors (
+    platform_id UUID NOT NULL,
+    mode VARCHAR(50) NOT NULL, -- Example: 'incremental', 'backfill'
+    cursor TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (platform_id, mode),
+    FOREIGN KEY (platform_id) REFERENCES platforms(id)
+);
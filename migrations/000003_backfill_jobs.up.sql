@@ -0,0 +1,21 @@
+CREATE TABLE backfill_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    platform_id UUID NOT NULL,
+    status VARCHAR(50) NOT NULL, -- Example: 'pending', 'running', 'completed', 'failed'
+    target_reviews INTEGER NULL,
+    target_since TIMESTAMP NULL,
+    page_delay_seconds INTEGER NOT NULL DEFAULT 0,
+    reviews_fetched INTEGER NOT NULL DEFAULT 0,
+    pages_fetched INTEGER NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    started_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (platform_id) REFERENCES platforms(id)
+);
+
+-- Only one unfinished backfill per platform
+CREATE UNIQUE INDEX backfill_jobs_active_platform_idx
+ON backfill_jobs (platform_id)
+WHERE status IN ('pending', 'running');
@@ -0,0 +1,4 @@
+ALTER TABLE reviews
+DROP COLUMN IF EXISTS response_body,
+DROP COLUMN IF EXISTS response_author,
+DROP COLUMN IF EXISTS response_date;
@@ -0,0 +1,4 @@
+ALTER TABLE reviews
+ADD COLUMN response_body TEXT NULL,
+ADD COLUMN response_author VARCHAR(255) NULL,
+ADD COLUMN response_date TIMESTAMP NULL;
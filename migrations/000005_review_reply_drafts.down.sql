@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS review_reply_drafts CASCADE;
+DROP TABLE IF EXISTS brand_tone_settings CASCADE;
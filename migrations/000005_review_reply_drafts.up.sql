@@ -0,0 +1,28 @@
+CREATE TABLE brand_tone_settings (
+    product_id UUID PRIMARY KEY,
+    tone VARCHAR(255) NOT NULL, -- Example: 'friendly', 'formal'
+    guidelines TEXT NOT NULL DEFAULT '',
+    signature VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE review_reply_drafts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    review_id UUID NOT NULL,
+    created_by UUID NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(50) NOT NULL, -- Example: 'draft', 'approved', 'posted'
+    model VARCHAR(255) NOT NULL,
+    approved_by UUID NULL,
+    approved_at TIMESTAMP NULL,
+    posted_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (review_id) REFERENCES reviews(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (approved_by) REFERENCES users(id)
+);
+
+CREATE INDEX review_reply_drafts_review_id_idx ON review_reply_drafts (review_id);
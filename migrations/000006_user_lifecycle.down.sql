@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS webhook_events CASCADE;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS is_deleted,
+DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,10 @@
+ALTER TABLE users
+ADD COLUMN is_deleted BOOLEAN DEFAULT FALSE,
+ADD COLUMN deleted_at TIMESTAMP NULL;
+
+CREATE TABLE webhook_events (
+    id VARCHAR(255) PRIMARY KEY, -- svix-id of the delivered message
+    source VARCHAR(50) NOT NULL, -- Example: 'clerk'
+    type VARCHAR(255) NOT NULL,
+    received_at TIMESTAMP DEFAULT NOW()
+);
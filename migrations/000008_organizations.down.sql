@@ -0,0 +1,4 @@
+ALTER TABLE products DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_members CASCADE;
+DROP TABLE IF EXISTS organizations CASCADE;
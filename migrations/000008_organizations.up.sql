@@ -0,0 +1,37 @@
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE organization_members (
+    organization_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    role VARCHAR(50) NOT NULL, -- 'owner', 'admin' or 'viewer'
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON organization_members (user_id);
+
+-- Every existing user gets a personal organization, reusing their id so their
+-- products can be moved into it
+INSERT INTO organizations (id, name)
+SELECT u.id, u.name || '''s workspace'
+FROM users u;
+
+INSERT INTO organization_members (organization_id, user_id, role)
+SELECT u.id, u.id, 'owner'
+FROM users u
+WHERE u.is_deleted = FALSE;
+
+-- products.user_id is kept as the product's creator
+ALTER TABLE products ADD COLUMN organization_id UUID REFERENCES organizations(id);
+UPDATE products SET organization_id = user_id;
+ALTER TABLE products ALTER COLUMN organization_id SET NOT NULL;
+
+CREATE INDEX products_organization_id_idx ON products (organization_id);
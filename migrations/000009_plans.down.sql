@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stats_generations CASCADE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
@@ -0,0 +1,19 @@
+CREATE TABLE llm_usage (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(50) NOT NULL,
+    model VARCHAR(255) NOT NULL,
+    operation VARCHAR(50) NOT NULL, -- Example: 'summary', 'sentiment', 'reply'
+    product_id UUID NULL,
+    user_id UUID NULL, -- NULL for calls made by scheduled jobs
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    latency_ms INTEGER NOT NULL,
+    cost_usd NUMERIC(12,6) NOT NULL DEFAULT 0,
+    succeeded BOOLEAN NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX llm_usage_product_id_created_at_idx ON llm_usage (product_id, created_at);
+CREATE INDEX llm_usage_user_id_created_at_idx ON llm_usage (user_id, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE product_stats
+DROP COLUMN IF EXISTS summary_prompt_version,
+DROP COLUMN IF EXISTS sentiment_prompt_version;
@@ -0,0 +1,5 @@
+-- The prompts/<name>/<version> templates that produced each row, NULL for
+-- rows generated before prompts were versioned
+ALTER TABLE product_stats
+ADD COLUMN summary_prompt_version VARCHAR(255) NULL,
+ADD COLUMN sentiment_prompt_version VARCHAR(255) NULL;
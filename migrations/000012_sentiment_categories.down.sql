@@ -0,0 +1 @@
+DROP TABLE IF EXISTS sentiment_categories CASCADE;
@@ -0,0 +1,13 @@
+CREATE TABLE sentiment_categories (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    example_phrases TEXT[] NOT NULL DEFAULT '{}',
+    position INTEGER NOT NULL, -- Order categories are shown and prompted in
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE INDEX sentiment_categories_product_id_idx ON sentiment_categories (product_id, position);
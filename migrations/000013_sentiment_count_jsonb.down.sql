@@ -0,0 +1,18 @@
+ALTER TABLE product_stats
+ADD COLUMN sentiment_count_text TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE product_stats
+SET sentiment_count_text = ARRAY(
+    SELECT CAST(counts.element AS TEXT)
+    FROM jsonb_array_elements(sentiment_count) WITH ORDINALITY AS counts(element, position)
+    ORDER BY counts.position
+);
+
+ALTER TABLE product_stats
+DROP COLUMN sentiment_count;
+
+ALTER TABLE product_stats
+RENAME COLUMN sentiment_count_text TO sentiment_count;
+
+ALTER TABLE product_stats
+ALTER COLUMN sentiment_count DROP DEFAULT;
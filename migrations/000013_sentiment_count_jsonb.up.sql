@@ -0,0 +1,16 @@
+-- sentiment_count held each category's counts as a JSON string inside a
+-- TEXT[], it becomes a JSONB array of the count objects
+ALTER TABLE product_stats
+ADD COLUMN sentiment_count_json JSONB NOT NULL DEFAULT '[]';
+
+UPDATE product_stats
+SET sentiment_count_json = COALESCE((
+    SELECT jsonb_agg(CAST(counts.element AS JSONB) ORDER BY counts.position)
+    FROM unnest(sentiment_count) WITH ORDINALITY AS counts(element, position)
+), '[]');
+
+ALTER TABLE product_stats
+DROP COLUMN sentiment_count;
+
+ALTER TABLE product_stats
+RENAME COLUMN sentiment_count_json TO sentiment_count;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reviews_search_idx;
+DROP TABLE IF EXISTS conversation_messages CASCADE;
+DROP TABLE IF EXISTS conversations CASCADE;
@@ -0,0 +1,29 @@
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    title VARCHAR(255) NOT NULL, -- The first question, shortened
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX conversations_product_id_user_id_idx ON conversations (product_id, user_id, updated_at DESC);
+
+CREATE TABLE conversation_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL,
+    role VARCHAR(50) NOT NULL, -- Example: 'user', 'assistant'
+    content TEXT NOT NULL,
+    review_ids UUID[] NOT NULL DEFAULT '{}', -- Reviews an answer cites
+    prompt_version VARCHAR(255) NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX conversation_messages_conversation_id_idx ON conversation_messages (conversation_id, created_at);
+
+-- Full text search over reviews for questions, must match the expression in
+-- models.querySearchReviews
+CREATE INDEX reviews_search_idx ON reviews USING GIN (to_tsvector('english', COALESCE(headline, '') || ' ' || COALESCE(review_body, '')));
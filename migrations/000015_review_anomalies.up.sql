@@ -0,0 +1,16 @@
+CREATE TABLE review_anomalies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL,
+    platform VARCHAR(255) NOT NULL, -- Platform name, or 'all' across platforms
+    date DATE NOT NULL,
+    metric VARCHAR(50) NOT NULL, -- Example: 'review_count', 'average_rating'
+    direction VARCHAR(50) NOT NULL, -- Example: 'spike', 'drop'
+    value DOUBLE PRECISION NOT NULL,
+    baseline_mean DOUBLE PRECISION NOT NULL,
+    baseline_stddev DOUBLE PRECISION NOT NULL,
+    z_score DOUBLE PRECISION NOT NULL,
+    review_count INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    UNIQUE (product_id, platform, date, metric)
+);
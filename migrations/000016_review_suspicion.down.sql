@@ -0,0 +1,5 @@
+ALTER TABLE reviews
+DROP COLUMN IF EXISTS suspicion_score,
+DROP COLUMN IF EXISTS suspicion_reasons,
+DROP COLUMN IF EXISTS suspicion_llm_score,
+DROP COLUMN IF EXISTS suspicion_scored_at;
@@ -0,0 +1,5 @@
+ALTER TABLE reviews
+ADD COLUMN suspicion_score DOUBLE PRECISION NULL, -- 0 to 1, NULL until the review is scored
+ADD COLUMN suspicion_reasons TEXT[] NOT NULL DEFAULT '{}', -- Example: 'duplicate_text', 'burst'
+ADD COLUMN suspicion_llm_score DOUBLE PRECISION NULL, -- The LLM's judgment, NULL when it was not asked
+ADD COLUMN suspicion_scored_at TIMESTAMP NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_clerk_id_active_key;
+DROP INDEX IF EXISTS users_email_active_key;
+
+ALTER TABLE users
+ADD CONSTRAINT users_clerk_id_key UNIQUE (clerk_id),
+ADD CONSTRAINT users_email_key UNIQUE (email);
@@ -0,0 +1,8 @@
+-- Soft deleted users keep their email and clerk_id, so only active users need
+-- them unique for a deleted user to be able to sign up again
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS users_clerk_id_key,
+DROP CONSTRAINT IF EXISTS users_email_key;
+
+CREATE UNIQUE INDEX users_clerk_id_active_key ON users (clerk_id) WHERE is_deleted = FALSE;
+CREATE UNIQUE INDEX users_email_active_key ON users (email) WHERE is_deleted = FALSE;